@@ -24,6 +24,12 @@ func main() {
 		SetConfig().
 		SetLogger().
 		SetDatabaseRepositories().
+		SetCacheRepositories().
+		SetHttpClient().
+		SetChallenge().
+		SetNotifier().
+		SetTls().
+		SetTokenCodec().
 		SetServices().
 		SetHandler().
 		Build()
@@ -34,7 +40,12 @@ func main() {
 	// Start the HTTP server in a goroutine
 	go func() {
 		addr := fmt.Sprintf(":%d", port)
-		if err := server.ListenAndServe(addr); err != nil && err != http.ErrServerClosed {
+		serve := server.ListenAndServe
+		if server.TLSConfig != nil {
+			// The certificate is already loaded in the TLS config
+			serve = func(addr string) error { return server.ListenAndServeTLS(addr, "", "") }
+		}
+		if err := serve(addr); err != nil && err != http.ErrServerClosed {
 			logger.Error("Server startup error", zap.Error(err))
 			os.Exit(1)
 		}
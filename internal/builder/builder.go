@@ -2,17 +2,25 @@ package builder
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/services"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/cache"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/challenge"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/codec"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/database"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/notifier"
+	cacheRepository "github.com/bhupendra-dudhwal/sso-gateway/internal/egress/repository/cache"
 	databaseRepository "github.com/bhupendra-dudhwal/sso-gateway/internal/egress/repository/database"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/handler"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/middleware"
@@ -33,9 +41,10 @@ type appBuilder struct {
 	config *models.Config
 	// logger ports.Logger
 
-	// // Client
+	// Client
 	// dbClient    *gorm.DB
-	// cacheClient *redis.Client
+	cacheClient *redis.Client
+	clientCAs   *x509.CertPool
 
 	// httpClient egress.HttpClientPorts
 
@@ -128,23 +137,65 @@ func (a *appBuilder) SetDatabaseRepositories() *appBuilder {
 	a.egressRepository.User = databaseRepository.NewUserRepository(client)
 	a.egressRepository.LoginHistory = databaseRepository.NewloginHistoryRepository(client)
 	a.egressRepository.Permission = databaseRepository.NewPermissionRepository(client)
+	a.egressRepository.Device = databaseRepository.NewTrustedDeviceRepository(client)
+	a.egressRepository.Session = databaseRepository.NewSessionRepository(client)
+	a.egressRepository.Client = databaseRepository.NewClientRepository(client)
+	a.egressRepository.AccessToken = databaseRepository.NewAccessTokenRepository(client)
+	a.egressRepository.ServiceAccount = databaseRepository.NewServiceAccountRepository(client)
+	a.egressRepository.Certificate = databaseRepository.NewCertificateBindingRepository(client)
+	a.egressRepository.Impersonation = databaseRepository.NewImpersonationRepository(client)
+
+	return a
+}
+
+func (a *appBuilder) SetCacheRepositories() *appBuilder {
+	client := a.setCache()
+	a.cacheClient = client
+
+	a.egressRepository.Cache = cacheRepository.NewCacheRepository(a.config.Cache, client)
+	a.egressRepository.RateLimit = cacheRepository.NewRateLimitRepository(client)
+	a.egressRepository.Replay = cacheRepository.NewReplayRepository(client)
+	if a.config.Session != nil {
+		a.egressRepository.Session = cacheRepository.NewSessionRepository(a.config.Session.Prefix, client, a.egressRepository.Session)
+	}
+	if a.config.Abuse != nil {
+		a.egressRepository.Abuse = cacheRepository.NewAbuseRepository(a.config.Abuse.Prefix, client)
+	}
 
 	return a
 }
 
 func (a *appBuilder) SetServices() *appBuilder {
 	a.ingressRepository.Health = services.NewHealthService(a.config, a.repository.Logger)
-	a.ingressRepository.Token = services.NewTokenService(a.config, a.repository.Logger)
+	a.ingressRepository.Token = services.NewTokenService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Abuse = services.NewAbuseService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Risk = services.NewRiskService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Session = services.NewSessionService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Device = services.NewDeviceService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Dpop = services.NewDpopService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Mtls = services.NewMtlsService(a.config, a.repository.Logger, a.egressRepository, a.clientCAs)
+	a.ingressRepository.ServiceAccount = services.NewServiceAccountService(a.config, a.repository.Logger, a.egressRepository, a.ingressRepository)
+	a.ingressRepository.OAuth = services.NewOAuthService(a.config, a.repository.Logger, a.egressRepository, a.ingressRepository)
 	a.ingressRepository.Auth = services.NewAuthService(a.config, a.repository, a.egressRepository, a.ingressRepository)
 	a.ingressRepository.Role = services.NewRoleService(a.config, a.repository.Logger, a.egressRepository)
-	a.ingressRepository.Permission = services.NewPermissionService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.Permission = services.NewPermissionService(a.config, a.repository.Logger, a.egressRepository, a.ingressRepository)
+	a.ingressRepository.Client = services.NewClientService(a.config, a.repository.Logger, a.egressRepository)
+	a.ingressRepository.AccessToken = services.NewAccessTokenService(a.config, a.repository.Logger, a.egressRepository)
 	a.ingressRepository.User = services.NewUserService(a.config, a.repository.Logger)
 
+	// Logout tokens are signed on demand, a bad key must stop the start instead of the first logout
+	if a.config.Sso != nil && a.config.Sso.Enabled {
+		if _, err := a.ingressRepository.Token.LogoutKeys(); err != nil {
+			a.repository.Logger.Error("logout signing key error", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	return a
 }
 
 func (a *appBuilder) SetHandler() *appBuilder {
-	middlewarePorts := middleware.NewMiddleWare(a.config, a.repository.Logger, a.ingressRepository.Token)
+	middlewarePorts := middleware.NewMiddleWare(a.config, a.repository.Logger, a.ingressRepository.Token, a.ingressRepository.Session, a.ingressRepository.AccessToken, a.ingressRepository.Mtls, a.ingressRepository.Dpop, a.egressRepository)
 	routes, handlerObj := handler.NewHandler(middlewarePorts)
 
 	handlerObj.SetHealthHandler(a.ingressRepository.Health)
@@ -152,6 +203,14 @@ func (a *appBuilder) SetHandler() *appBuilder {
 	handlerObj.SetRoleHandler(a.ingressRepository.Role)
 	handlerObj.SetPermissionHandler(a.ingressRepository.Permission)
 	handlerObj.SetUserHandler(a.ingressRepository.User)
+	handlerObj.SetSecurityHandler(a.ingressRepository.Abuse)
+	handlerObj.SetDeviceHandler(a.ingressRepository.Device)
+	handlerObj.SetSessionHandler(a.ingressRepository.Session)
+	handlerObj.SetClientHandler(a.ingressRepository.Client)
+	handlerObj.SetOAuthHandler(a.ingressRepository.OAuth)
+	handlerObj.SetAccessTokenHandler(a.ingressRepository.AccessToken)
+	handlerObj.SetServiceAccountHandler(a.ingressRepository.ServiceAccount)
+	handlerObj.SetCertificateHandler(a.ingressRepository.Mtls)
 	a.handler = routes
 
 	return a
@@ -168,6 +227,92 @@ func (a *appBuilder) SetHttpClient() *appBuilder {
 	return a
 }
 
+// Call after SetCacheRepositories and SetHttpClient
+func (a *appBuilder) SetChallenge() *appBuilder {
+	cfg := a.config.Challenge
+	if cfg == nil || !cfg.Enabled {
+		return a
+	}
+
+	switch cfg.Provider {
+	case constants.ChallengePow:
+		a.egressRepository.Challenge = challenge.NewPow(cfg.Pow, cacheRepository.NewChallengeRepository(a.cacheClient))
+	default:
+		a.egressRepository.Challenge = challenge.NewCaptcha(cfg.Provider, cfg.Captcha, a.egressRepository.HttpClient)
+	}
+
+	return a
+}
+
+// SetTls terminates TLS with client certificate verification when mTLS is enabled, unless a
+// proxy terminates it and forwards the certificate
+func (a *appBuilder) SetTls() *appBuilder {
+	cfg := a.config.Mtls
+	if cfg == nil || !cfg.Enabled {
+		return a
+	}
+
+	pool, err := utils.LoadCertPool(cfg.ClientCAFiles)
+	if err != nil {
+		a.repository.Logger.Error("client CA error", zap.Error(err))
+		os.Exit(1)
+	}
+	a.clientCAs = pool
+
+	if cfg.CertHeader != "" {
+		return a
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		a.repository.Logger.Error("server certificate error", zap.Error(err))
+		os.Exit(1)
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if cfg.RequireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	a.server.TLSConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   clientAuth,
+	}
+	return a
+}
+
+// SetTokenCodec picks the format of the access tokens
+func (a *appBuilder) SetTokenCodec() *appBuilder {
+	cfg := a.config.Jwt
+
+	switch cfg.Codec {
+	case constants.CodecPasetoPublic, constants.CodecPasetoLocal:
+		key, err := hex.DecodeString(cfg.PasetoKey)
+		if err != nil {
+			a.repository.Logger.Error("paseto key error", zap.Error(err))
+			os.Exit(1)
+		}
+
+		tokenCodec, err := codec.NewPaseto(cfg.Codec, key)
+		if err != nil {
+			a.repository.Logger.Error("paseto key error", zap.Error(err))
+			os.Exit(1)
+		}
+		a.egressRepository.TokenCodec = tokenCodec
+	default:
+		a.egressRepository.TokenCodec = codec.NewJwt(cfg.SecretKey)
+	}
+
+	return a
+}
+
+func (a *appBuilder) SetNotifier() *appBuilder {
+	a.egressRepository.OtpSender = notifier.NewLogSender(a.repository.Logger, a.config.App.Server.Environment)
+	return a
+}
+
 func (a *appBuilder) Build() (ports.Logger, *fasthttp.Server, int) {
 	a.server.Handler = a.handler
 	return a.repository.Logger, a.server, a.config.App.Server.Port
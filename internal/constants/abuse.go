@@ -0,0 +1,17 @@
+package constants
+
+type AbuseAction string
+
+const (
+	AbuseActionNone      AbuseAction = "none"
+	AbuseActionChallenge AbuseAction = "challenge" // Caller must solve a challenge before credentials are checked
+	AbuseActionBlock     AbuseAction = "block"     // Source is temporarily blocked
+)
+
+type AlertType string
+
+const (
+	AlertPasswordSpraying   AlertType = "password_spraying"   // One source failing against many accounts
+	AlertCredentialStuffing AlertType = "credential_stuffing" // Many sources failing against one account
+	AlertSourceBlocked      AlertType = "source_blocked"
+)
@@ -0,0 +1,18 @@
+package constants
+
+// Acr is the authentication context class of a token, higher levels are stronger
+type Acr string
+
+const (
+	AcrSingleFactor Acr = "aal1"
+	AcrMultiFactor  Acr = "aal2"
+)
+
+// Authentication methods references
+const (
+	AmrPassword string = "pwd"
+	AmrOtp      string = "otp"
+)
+
+// ErrInsufficientUserAuthentication asks the client to authenticate again more strongly
+const ErrInsufficientUserAuthentication string = "insufficient_user_authentication"
@@ -10,14 +10,28 @@ const (
 type Header string
 
 const (
-	ContentType     Header = "Content-Type"
-	ContentEncoding Header = "Content-Encoding"
+	ContentType        Header = "Content-Type"
+	ContentEncoding    Header = "Content-Encoding"
+	RetryAfter         Header = "Retry-After"
+	WWWAuthenticate    Header = "WWW-Authenticate"
+	RateLimitLimit     Header = "RateLimit-Limit"
+	RateLimitRemaining Header = "RateLimit-Remaining"
+	RateLimitReset     Header = "RateLimit-Reset"
+	XForwardedFor      Header = "X-Forwarded-For"
+	XRealIP            Header = "X-Real-IP"
+	XClientID          Header = "X-Client-ID"
+	UserAgent          Header = "User-Agent"
+	XCsrfToken         Header = "X-CSRF-Token"
+	CacheControl       Header = "Cache-Control"
+	Dpop               Header = "DPoP"
 )
 
 type ContentTypes string
 
 const (
-	Json ContentTypes = "application/json"
+	Json           ContentTypes = "application/json"
+	FormUrlEncoded ContentTypes = "application/x-www-form-urlencoded"
+	Html           ContentTypes = "text/html; charset=utf-8"
 )
 
 type Compression string
@@ -28,14 +42,16 @@ const (
 
 type Roles string
 
+// Roles are defined in the database, only those the code relies on are named here
 const (
 	RoleSessionUser   Roles = "session_user"   // With session
 	RoleAnonymousUser Roles = "anonymous_user" // without session
 	RoleSystemAdmin   Roles = "system_admin"
-	Roleadmin         Roles = "admin"
-	Roleuser          Roles = "user"
 )
 
+// SystemRoles are flagged as system roles when created, they cannot be deleted or deactivated
+var SystemRoles = []Roles{RoleSessionUser, RoleAnonymousUser, RoleSystemAdmin}
+
 type Status string
 
 const (
@@ -60,4 +76,10 @@ const (
 const (
 	Authorization string = "Authorization"
 	AuthType      string = "Bearer "
+	DpopAuthType  string = "DPoP " // Scheme of sender constrained tokens, see RFC 9449
+
+	// AccessTokenPrefix tells personal access tokens apart from JWTs and lets secret scanners find them
+	AccessTokenPrefix string = "ssopat_"
+	// OpaqueTokenPrefix tells opaque access tokens apart from JWTs and personal access tokens
+	OpaqueTokenPrefix string = "ssoat_"
 )
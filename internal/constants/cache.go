@@ -6,3 +6,18 @@ const (
 	CacheAdd    CacheStrategy = "add"
 	CacheUpdate CacheStrategy = "update"
 )
+
+const (
+	CacheKeyMfa             string = "mfa:%s"               // Pending second factor by mfa token
+	CacheKeyMfaAttempts     string = "mfa:attempts:%s"      // Verification attempts of a pending second factor by mfa token
+	CacheKeyRevokedToken    string = "token:revoked:%s"     // Denied access token by jti
+	CacheKeyTokenCutoff     string = "token:cutoff:%d"      // Tokens of the user issued before this unix time are rejected
+	CacheKeyAuthCode        string = "oauth:code:%s"        // Pending authorization code by code hash
+	CacheKeyDeviceCode      string = "oauth:device:%s"      // Pending device authorization by device code hash
+	CacheKeyDevicePoll      string = "oauth:device_poll:%s" // Poll pacing of a pending device authorization by device code hash
+	CacheKeyUserCode        string = "oauth:user_code:%s"   // Device code hash by normalized user code
+	CacheKeySaCutoff        string = "sa:cutoff:%s"         // Tokens of the service account issued before this unix time are rejected
+	CacheKeyOpaqueToken     string = "token:opaque:%s"      // Claims of an opaque access token by token hash
+	CacheKeyPermissionIndex string = "perm:index:%s"        // Permission index by version
+	CacheKeyIPFailures      string = "challenge:ip:%s"      // Failed signins from an IP within the challenge window
+)
@@ -0,0 +1,11 @@
+package constants
+
+// CertificateMatch names the client certificate field a binding is compared with
+type CertificateMatch string
+
+const (
+	CertMatchSubject CertificateMatch = "subject"   // Full subject distinguished name
+	CertMatchDNS     CertificateMatch = "san_dns"   // DNS name SAN
+	CertMatchEmail   CertificateMatch = "san_email" // Email address SAN
+	CertMatchURI     CertificateMatch = "san_uri"   // URI SAN, e.g. a SPIFFE id
+)
@@ -0,0 +1,14 @@
+package constants
+
+type ChallengeProvider string
+
+const (
+	ChallengePow       ChallengeProvider = "pow"       // Self-hosted SHA-256 proof of work
+	ChallengeRecaptcha ChallengeProvider = "recaptcha" // Google reCAPTCHA
+	ChallengeHcaptcha  ChallengeProvider = "hcaptcha"  // hCaptcha
+)
+
+const (
+	RecaptchaVerifyURL string = "https://www.google.com/recaptcha/api/siteverify"
+	HcaptchaVerifyURL  string = "https://api.hcaptcha.com/siteverify"
+)
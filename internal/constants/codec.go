@@ -0,0 +1,28 @@
+package constants
+
+// TokenCodec is the format the token service signs or encrypts its tokens with
+type TokenCodec string
+
+const (
+	CodecJwt          TokenCodec = "jwt"              // HS256 JWT signed with the JWT secret key
+	CodecPasetoPublic TokenCodec = "paseto.v4.public" // Ed25519 signed PASETO
+	CodecPasetoLocal  TokenCodec = "paseto.v4.local"  // XChaCha20 encrypted and BLAKE2b authenticated PASETO
+)
+
+// PermissionEncoding is how the permissions of a token are written into it
+type PermissionEncoding string
+
+const (
+	PermissionEncodingList   PermissionEncoding = "list"   // Object of permission names
+	PermissionEncodingBitmap PermissionEncoding = "bitmap" // Bits of the published permission index
+	PermissionEncodingRole   PermissionEncoding = "role"   // Reference to the role, resolved by the gateway
+)
+
+// ReservedClaims are set by the gateway, claim mappings cannot add or override them. Every JSON
+// name of models.Token must be listed.
+var ReservedClaims = []string{
+	"iss", "sub", "aud", "exp", "nbf", "iat", "jti",
+	"user_id", "role", "permission", "pbm", "rpm", "sid", "acr", "amr", "auth_time",
+	"service_account_id", "cnf", "act",
+	"scope", "client_id", "azp", "nonce", "typ", "events",
+}
@@ -4,4 +4,5 @@ type Context string
 
 const (
 	CtxRequestID Context = "request_id"
+	CtxTokenInfo Context = "token_info" // *models.Token of the authorized request
 )
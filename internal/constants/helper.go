@@ -32,3 +32,63 @@ func (s Status) String() string {
 func (r Roles) String() string {
 	return string(r)
 }
+
+func (r RateLimitKey) String() string {
+	return string(r)
+}
+
+func (a AbuseAction) String() string {
+	return string(a)
+}
+
+func (a AlertType) String() string {
+	return string(a)
+}
+
+func (c ChallengeProvider) String() string {
+	return string(c)
+}
+
+func (r RiskDecision) String() string {
+	return string(r)
+}
+
+func (s ServiceAccountAction) String() string {
+	return string(s)
+}
+
+func (c CertificateMatch) String() string {
+	return string(c)
+}
+
+func (t TokenFormat) String() string {
+	return string(t)
+}
+
+func (t TokenCodec) String() string {
+	return string(t)
+}
+
+func (p PermissionEncoding) String() string {
+	return string(p)
+}
+
+func (d DeviceStatus) String() string {
+	return string(d)
+}
+
+func (a Acr) String() string {
+	return string(a)
+}
+
+// Level ranks the class, unknown classes rank below every known one
+func (a Acr) Level() int {
+	switch a {
+	case AcrSingleFactor:
+		return 1
+	case AcrMultiFactor:
+		return 2
+	default:
+		return 0
+	}
+}
@@ -23,9 +23,69 @@ const (
 	PrmInfoRole    string = "info_role"    // Can view info of roles
 	PrmAddRoles    string = "add_roles"    // Can add roles
 
+	// Security
+	PrmListBlockedSources string = "list_blocked_sources" // Can list sources blocked by abuse detection
+	PrmUnblockSource      string = "unblock_source"       // Can lift a block on a source
+
 	// Auth
-	PrmSignin    string = "signin"
-	PrmSignup    string = "signup"
-	PrmOtpSend   string = "otp_send"
-	PrmOtpVerify string = "otp_verify"
+	PrmSignin         string = "signin"
+	PrmSignup         string = "signup"
+	PrmOtpSend        string = "otp_send"
+	PrmOtpVerify      string = "otp_verify"
+	PrmChangePassword string = "change_password" // Can change own password
+
+	// Devices
+	PrmListDevices  string = "list_devices"  // Can list own trusted devices
+	PrmRevokeDevice string = "revoke_device" // Can revoke own trusted devices
+
+	// Personal access tokens
+	PrmListAccessTokens  string = "list_access_tokens"  // Can list own access tokens
+	PrmAddAccessToken    string = "add_access_token"    // Can create access tokens
+	PrmRevokeAccessToken string = "revoke_access_token" // Can revoke own access tokens
+
+	// Service accounts
+	PrmListServiceAccounts  string = "list_service_accounts"  // Can list service accounts and their audit trail
+	PrmAddServiceAccount    string = "add_service_account"    // Can create service accounts
+	PrmEditServiceAccount   string = "edit_service_account"   // Can change roles and keys of service accounts
+	PrmDeleteServiceAccount string = "delete_service_account" // Can disable service accounts
+
+	// Client certificates
+	PrmListCertificateBindings  string = "list_certificate_bindings"  // Can list client certificate bindings
+	PrmAddCertificateBinding    string = "add_certificate_binding"    // Can bind client certificates to principals
+	PrmDeleteCertificateBinding string = "delete_certificate_binding" // Can remove client certificate bindings
+
+	// Clients
+	PrmListClients  string = "list_clients"  // Can list registered applications
+	PrmAddClient    string = "add_client"    // Can register applications
+	PrmDeleteClient string = "delete_client" // Can remove applications
+	PrmEditClient   string = "edit_client"   // Can change the claim mapping of applications
+
+	// Sessions
+	PrmListSessions  string = "list_sessions"  // Can list own sessions
+	PrmRevokeSession string = "revoke_session" // Can revoke own sessions
+	PrmTokenRefresh  string = "token_refresh"  // Can exchange a refresh token
+	PrmLogout        string = "logout"         // Can sign out of own sessions
+	PrmForceLogout   string = "force_logout"   // Can sign out any user everywhere
+	PrmStepUp        string = "step_up"        // Can re-authenticate the current session with a second factor
+	PrmApproveDevice string = "approve_device" // Can approve the sign in of a device with its user code
+
+	// Token exchange
+	PrmTokenExchange      string = "exchange_token"      // Can exchange tokens of other principals, acting on their behalf
+	PrmImpersonate        string = "impersonate_user"    // Can obtain a token of any user for support
+	PrmListImpersonations string = "list_impersonations" // Can list the impersonation audit trail
 )
+
+// AdminWritePermissions guard administrative changes, a token acting on behalf of someone else
+// never carries them and is denied them even when they appear in its claims
+var AdminWritePermissions = []string{
+	PrmEditUser, PrmDeleteUser, PrmAdduser,
+	PrmEditPermissions, PrmDeletePermissions, PrmAddPermissions,
+	PrmEditRoles, PrmDeleteRoles, PrmAddRoles,
+	PrmUnblockSource,
+	PrmAddServiceAccount, PrmEditServiceAccount, PrmDeleteServiceAccount,
+	PrmAddCertificateBinding, PrmDeleteCertificateBinding,
+	PrmAddClient, PrmDeleteClient, PrmEditClient,
+	PrmForceLogout,
+	PrmTokenExchange, PrmImpersonate,
+	PrmAddAccessToken, PrmRevokeAccessToken,
+}
@@ -0,0 +1,70 @@
+package constants
+
+// Prompt values of an authorization request
+const (
+	PromptNone  string = "none"  // Never show the login page, fail with login_required instead
+	PromptLogin string = "login" // Always authenticate again, even with a live SSO session
+)
+
+const (
+	ResponseTypeCode string = "code"
+
+	GrantAuthorizationCode string = "authorization_code"
+	GrantClientCredentials string = "client_credentials"
+	GrantJwtBearer         string = "urn:ietf:params:oauth:grant-type:jwt-bearer"     // RFC 7523 assertion grant
+	GrantDeviceCode        string = "urn:ietf:params:oauth:grant-type:device_code"    // RFC 8628 device authorization grant
+	GrantTokenExchange     string = "urn:ietf:params:oauth:grant-type:token-exchange" // RFC 8693 token exchange
+
+	// Token types of a token exchange
+	TokenTypeAccessToken string = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJwt         string = "urn:ietf:params:oauth:token-type:jwt"
+
+	ClientAssertionJwtBearer string = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" // private_key_jwt
+
+	CodeChallengeS256 string = "S256"
+
+	TokenTypeBearer string = "Bearer"
+	TokenTypeDpop   string = "DPoP"
+
+	DpopProofType string = "dpop+jwt" // typ header of a DPoP proof
+
+	// BackchannelLogoutEvent marks a JWT as an OpenID Connect logout token
+	BackchannelLogoutEvent string = "http://schemas.openid.net/event/backchannel-logout"
+	// LogoutTokenType is the typ header of logout tokens, it keeps them from being taken for access tokens
+	LogoutTokenType string = "logout+jwt"
+)
+
+// OAuth error codes
+const (
+	OAuthInvalidRequest          string = "invalid_request"
+	OAuthInvalidClient           string = "invalid_client"
+	OAuthInvalidGrant            string = "invalid_grant"
+	OAuthUnauthorizedClient      string = "unauthorized_client"
+	OAuthUnsupportedGrantType    string = "unsupported_grant_type"
+	OAuthUnsupportedResponseType string = "unsupported_response_type"
+	OAuthLoginRequired           string = "login_required"
+	OAuthServerError             string = "server_error"
+	OAuthInvalidDpopProof        string = "invalid_dpop_proof"
+	OAuthAuthorizationPending    string = "authorization_pending"
+	OAuthSlowDown                string = "slow_down"
+	OAuthAccessDenied            string = "access_denied"
+	OAuthExpiredToken            string = "expired_token"
+	OAuthInvalidScope            string = "invalid_scope"
+)
+
+// TokenFormat is the format of the access tokens issued to a client
+type TokenFormat string
+
+const (
+	TokenFormatJwt    TokenFormat = "jwt"
+	TokenFormatOpaque TokenFormat = "opaque" // Random reference, the claims stay in the cache
+)
+
+// DeviceStatus is the state of a device authorization request
+type DeviceStatus string
+
+const (
+	DevicePending  DeviceStatus = "pending"
+	DeviceApproved DeviceStatus = "approved"
+	DeviceDenied   DeviceStatus = "denied"
+)
@@ -0,0 +1,12 @@
+package constants
+
+type RateLimitKey string
+
+const (
+	RateLimitKeyIP     RateLimitKey = "ip"     // Client IP address
+	RateLimitKeyUser   RateLimitKey = "user"   // User ID from the bearer token
+	RateLimitKeyEmail  RateLimitKey = "email"  // Email field in the JSON request body
+	RateLimitKeyClient RateLimitKey = "client" // API client identifier header
+)
+
+const RateLimitAnyPath string = "*"
@@ -0,0 +1,9 @@
+package constants
+
+type RiskDecision string
+
+const (
+	RiskAllow  RiskDecision = "allow"
+	RiskStepUp RiskDecision = "step_up" // Password is not enough, a second factor is required
+	RiskBlock  RiskDecision = "block"
+)
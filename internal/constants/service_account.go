@@ -0,0 +1,14 @@
+package constants
+
+// ServiceAccountAction names an entry of the service account audit trail
+type ServiceAccountAction string
+
+const (
+	SaActionCreated      ServiceAccountAction = "created"
+	SaActionRolesUpdated ServiceAccountAction = "roles_updated"
+	SaActionKeyAdded     ServiceAccountAction = "key_added"
+	SaActionKeyRevoked   ServiceAccountAction = "key_revoked"
+	SaActionDisabled     ServiceAccountAction = "disabled"
+	SaActionTokenIssued  ServiceAccountAction = "token_issued"
+	SaActionTokenDenied  ServiceAccountAction = "token_denied"
+)
@@ -0,0 +1,8 @@
+package constants
+
+type SessionLimitPolicy string
+
+const (
+	SessionLimitEvictOldest SessionLimitPolicy = "evict_oldest" // Signin succeeds and the oldest session is signed out
+	SessionLimitReject      SessionLimitPolicy = "reject"       // Signin fails until a session is signed out
+)
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+type AbuseVerdict struct {
+	Action         constants.AbuseAction `json:"action"`
+	Reasons        []string              `json:"reasons,omitempty"`
+	SourceAccounts int                   `json:"source_accounts"` // Distinct accounts failing from the source IP
+	AccountSources int                   `json:"account_sources"` // Distinct IPs failing against the account
+	BlockedUntil   time.Time             `json:"blocked_until,omitempty"`
+}
+
+// Escalate raises the verdict to action, never lowering it
+func (v *AbuseVerdict) Escalate(action constants.AbuseAction, reason string) {
+	if abuseActionRank(action) > abuseActionRank(v.Action) {
+		v.Action = action
+	}
+	v.Reasons = append(v.Reasons, reason)
+}
+
+func abuseActionRank(action constants.AbuseAction) int {
+	switch action {
+	case constants.AbuseActionChallenge:
+		return 1
+	case constants.AbuseActionBlock:
+		return 2
+	default:
+		return 0
+	}
+}
+
+type BlockedSource struct {
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	BlockedAt time.Time `json:"blocked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type AlertEvent struct {
+	Type      constants.AlertType `json:"type"`
+	Source    string              `json:"source,omitempty"`
+	Account   string              `json:"account,omitempty"`
+	Count     int                 `json:"count"`
+	Window    time.Duration       `json:"window"`
+	CreatedAt time.Time           `json:"created_at"`
+}
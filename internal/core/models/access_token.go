@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// AccessToken is a long lived credential for scripts, limited to a subset of the permissions
+// of its owner
+type AccessToken struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	UserID       int        `json:"user_id" gorm:"index;not null"`
+	Name         string     `json:"name"`
+	TokenHash    string     `json:"-" gorm:"not null"`
+	Permissions  []string   `json:"permissions" gorm:"type:text[]"`
+	AllowedCIDRs []string   `json:"allowed_cidrs,omitempty" gorm:"type:text[]"` // Empty allows any address
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP   string     `json:"last_used_ip,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+type AccessTokenRequest struct {
+	Name         string    `json:"name"`
+	Permissions  []string  `json:"permissions"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	AllowedCIDRs []string  `json:"allowed_cidrs"`
+}
+
+func (a *AccessTokenRequest) Sanitize() {
+	a.Name = utils.Sanitize(a.Name)
+	a.Permissions = utils.SanitizeLowerSlice(a.Permissions)
+	a.AllowedCIDRs = utils.SanitizeSlice(a.AllowedCIDRs)
+}
+
+func (a AccessTokenRequest) Validate(maxLifeSpan time.Duration) error {
+	now := time.Now()
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Name, validation.Required, validation.Length(3, 100)),
+		validation.Field(&a.Permissions, validation.Required),
+		validation.Field(&a.ExpiresAt, validation.Required, validation.Min(now), validation.Max(now.Add(maxLifeSpan))),
+		validation.Field(&a.AllowedCIDRs, validation.Each(utils.CIDRValidation())),
+	)
+}
+
+// AccessTokenCreated carries the token itself, which is only shown once
+type AccessTokenCreated struct {
+	*AccessToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// CertificateBinding maps a client certificate identity to the principal it authenticates,
+// exactly one of ServiceAccountID and UserID is set
+type CertificateBinding struct {
+	ID               string                     `json:"id" gorm:"primaryKey"`
+	Match            constants.CertificateMatch `json:"match"`
+	Value            string                     `json:"value"` // Compared exactly with the certificate field named by Match
+	ServiceAccountID string                     `json:"service_account_id,omitempty"`
+	UserID           int                        `json:"user_id,omitempty"`
+	CreatedBy        int                        `json:"created_by,omitempty"`
+	CreatedAt        time.Time                  `json:"created_at"`
+}
+
+type CertificateBindingRequest struct {
+	Match            constants.CertificateMatch `json:"match"`
+	Value            string                     `json:"value"`
+	ServiceAccountID string                     `json:"service_account_id"`
+	UserID           int                        `json:"user_id"`
+}
+
+func (c *CertificateBindingRequest) Sanitize() {
+	c.Value = utils.Sanitize(c.Value)
+	c.ServiceAccountID = utils.Sanitize(c.ServiceAccountID)
+}
+
+func (c CertificateBindingRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Match, validation.Required, validation.In(constants.CertMatchSubject, constants.CertMatchDNS, constants.CertMatchEmail, constants.CertMatchURI)),
+		validation.Field(&c.Value, validation.Required, validation.Length(1, 1024)),
+		validation.Field(&c.ServiceAccountID, validation.When(c.UserID == 0, validation.Required).Else(validation.Empty)),
+		validation.Field(&c.UserID, validation.Min(0)),
+	)
+}
+
+// Confirmation binds a token to a key of its holder, see RFC 7800
+type Confirmation struct {
+	X5tS256 string `json:"x5t#S256,omitempty"` // Thumbprint of the client certificate, see RFC 8705
+	Jkt     string `json:"jkt,omitempty"`      // Thumbprint of the DPoP proof key, see RFC 9449
+}
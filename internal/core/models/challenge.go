@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+// Challenge is handed to the client when signin requires one.
+// For proof of work the client finds a counter such that SHA-256("<challenge>:<counter>")
+// starts with Difficulty zero bits and submits "<challenge>:<counter>" as the challenge token.
+// For captcha providers the client renders the widget with SiteKey and submits its response token.
+type Challenge struct {
+	Provider   constants.ChallengeProvider `json:"provider"`
+	Challenge  string                      `json:"challenge,omitempty"`
+	Difficulty int                         `json:"difficulty,omitempty"`
+	SiteKey    string                      `json:"site_key,omitempty"`
+	ExpiresAt  time.Time                   `json:"expires_at,omitempty"`
+}
+
+type CaptchaVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      float64  `json:"score,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	Hostname   string   `json:"hostname,omitempty"`
+	ErrorCodes []string `json:"error-codes,omitempty"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"text/template"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+var claimNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.:-]{0,63}$`)
+
+// ClaimMapping names the extra claims of a token and the text/template producing each value, e.g.
+// {"tenant": "{{.Tenant}}", "dept": "{{.User.Attributes.department}}"}. Empty values are omitted.
+type ClaimMapping map[string]string
+
+func (c ClaimMapping) Validate() error {
+	for name, text := range c {
+		if !claimNamePattern.MatchString(name) {
+			return fmt.Errorf("claim %q: invalid name", name)
+		}
+		if slices.Contains(constants.ReservedClaims, name) {
+			return fmt.Errorf("claim %q is reserved", name)
+		}
+		if _, err := ParseClaimTemplate(name, text); err != nil {
+			return fmt.Errorf("claim %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ParseClaimTemplate compiles the template of a claim, a missing attribute renders as empty
+func ParseClaimTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+// ClaimData is what claim templates are evaluated against, it never exposes credentials of the user
+type ClaimData struct {
+	User   ClaimUser
+	Role   ClaimRole
+	Tenant string
+}
+
+type ClaimUser struct {
+	ID         int
+	UserName   string
+	Name       string
+	Email      string
+	Mobile     int
+	Attributes map[string]string
+}
+
+type ClaimRole struct {
+	ID          constants.Roles
+	Permissions []string
+}
+
+// NewClaimData collects the data of the token subject, user may be nil
+func NewClaimData(user *User, roleID constants.Roles, permissions []string) *ClaimData {
+	data := &ClaimData{
+		Role: ClaimRole{ID: roleID, Permissions: permissions},
+	}
+
+	if user != nil {
+		data.User = ClaimUser{
+			ID:         user.ID,
+			UserName:   user.UserName,
+			Name:       user.Name,
+			Email:      user.Email,
+			Mobile:     user.Mobile,
+			Attributes: user.Attributes,
+		}
+		data.Tenant = user.TenantID
+	}
+	return data
+}
+
+// withCustomClaims adds the custom claims to the JSON object of the standard ones, which win
+func withCustomClaims(data []byte, custom map[string]string) ([]byte, error) {
+	if len(custom) == 0 {
+		return data, nil
+	}
+
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+
+	for name, value := range custom {
+		if _, found := claims[name]; found || slices.Contains(constants.ReservedClaims, name) {
+			continue
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		claims[name] = encoded
+	}
+	return json.Marshal(claims)
+}
+
+// customClaims returns the string claims of a JSON object that are not reserved
+func customClaims(data []byte) (map[string]string, error) {
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+
+	var custom map[string]string
+	for name, raw := range claims {
+		if slices.Contains(constants.ReservedClaims, name) {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+		if custom == nil {
+			custom = make(map[string]string)
+		}
+		custom[name] = value
+	}
+	return custom, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Client is an application allowed to sign users in through the gateway
+type Client struct {
+	ID                    string                `json:"id" gorm:"primaryKey"`
+	Name                  string                `json:"name"`
+	SecretHash            string                `json:"-"` // Empty for public clients, which must use PKCE
+	RedirectURIs          []string              `json:"redirect_uris" gorm:"type:text[]"`
+	BackchannelLogoutURI  string                `json:"backchannel_logout_uri,omitempty"`  // Receives a signed logout token when a session of the client ends
+	FrontchannelLogoutURI string                `json:"frontchannel_logout_uri,omitempty"` // Loaded in an iframe of the gateway logout page
+	TokenFormat           constants.TokenFormat `json:"token_format" gorm:"default:jwt"`
+	ClaimMapping          ClaimMapping          `json:"claim_mapping,omitempty" gorm:"serializer:json"`
+	Status                constants.Status      `json:"status"`
+	CreatedBy             int                   `json:"created_by,omitempty"`
+	CreatedAt             time.Time             `json:"created_at"`
+}
+
+// Confidential reports whether the client authenticates with a secret
+func (c *Client) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// AllowsRedirect reports whether uri exactly matches a registered redirect URI
+func (c *Client) AllowsRedirect(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+type ClientRequest struct {
+	Name                  string                `json:"name"`
+	RedirectURIs          []string              `json:"redirect_uris"`
+	BackchannelLogoutURI  string                `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI string                `json:"frontchannel_logout_uri"`
+	Confidential          bool                  `json:"confidential"`
+	TokenFormat           constants.TokenFormat `json:"token_format"`
+	ClaimMapping          ClaimMapping          `json:"claim_mapping"`
+}
+
+func (c *ClientRequest) Sanitize() {
+	c.Name = utils.Sanitize(c.Name)
+	c.RedirectURIs = utils.SanitizeSlice(c.RedirectURIs)
+	c.BackchannelLogoutURI = utils.Sanitize(c.BackchannelLogoutURI)
+	c.FrontchannelLogoutURI = utils.Sanitize(c.FrontchannelLogoutURI)
+}
+
+func (c ClientRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Name, validation.Required, validation.Length(3, 100)),
+		validation.Field(&c.RedirectURIs, validation.Required, validation.Each(validation.Required, validation.Length(1, 2048))),
+		validation.Field(&c.BackchannelLogoutURI, validation.Length(0, 2048)),
+		validation.Field(&c.FrontchannelLogoutURI, validation.Length(0, 2048)),
+		validation.Field(&c.TokenFormat, validation.In(constants.TokenFormatJwt, constants.TokenFormatOpaque)),
+		validation.Field(&c.ClaimMapping),
+	)
+}
+
+// ClaimMappingRequest replaces the claim mapping of a client, an empty mapping removes it
+type ClaimMappingRequest struct {
+	ClaimMapping ClaimMapping `json:"claim_mapping"`
+}
+
+func (c ClaimMappingRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.ClaimMapping),
+	)
+}
+
+// ClientCreated carries the client secret, which is only shown once
+type ClientCreated struct {
+	*Client
+	Secret string `json:"secret,omitempty"`
+}
@@ -4,16 +4,35 @@ import (
 	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
 
 type Config struct {
-	App        *App        `yaml:"app"`
-	Logger     *Logger     `yaml:"logger"`
-	Database   *Database   `yaml:"database"`
-	Cache      *Cache      `yaml:"cache"`
-	Jwt        *Jwt        `yaml:"jwt"`
-	HttpClient *HttpClient `yaml:"httpClient"`
+	App            *App             `yaml:"app"`
+	Logger         *Logger          `yaml:"logger"`
+	Database       *Database        `yaml:"database"`
+	Cache          *Cache           `yaml:"cache"`
+	Jwt            *Jwt             `yaml:"jwt"`
+	HttpClient     *HttpClient      `yaml:"httpClient"`
+	RateLimit      *RateLimit       `yaml:"rateLimit"`
+	Abuse          *Abuse           `yaml:"abuse"`
+	Challenge      *SigninChallenge `yaml:"challenge"`
+	Risk           *Risk            `yaml:"risk"`
+	Device         *DeviceTrust     `yaml:"trustedDevice"`
+	Session        *SessionStore    `yaml:"session"`
+	StepUp         *StepUp          `yaml:"stepUp"`
+	Cookie         *CookieSession   `yaml:"cookieSession"`
+	Sso            *Sso             `yaml:"sso"`
+	AccessToken    *AccessTokens    `yaml:"accessToken"`
+	ServiceAccount *ServiceAccounts `yaml:"serviceAccount"`
+	Mtls           *Mtls            `yaml:"mtls"`
+	Dpop           *Dpop            `yaml:"dpop"`
+	DeviceFlow     *DeviceFlow      `yaml:"deviceAuthorization"`
+	TokenExchange  *TokenExchange   `yaml:"tokenExchange"`
+	OpaqueTokens   *OpaqueTokens    `yaml:"opaqueTokens"`
+	ClaimMappings  *ClaimMappings   `yaml:"claimMappings"`
 }
 
 func (c Config) Validate() error {
@@ -24,21 +43,48 @@ func (c Config) Validate() error {
 		validation.Field(&c.Cache, validation.Required, validation.NotNil),
 		validation.Field(&c.Jwt, validation.Required, validation.NotNil),
 		validation.Field(&c.HttpClient, validation.Required, validation.NotNil),
+		validation.Field(&c.RateLimit),
+		validation.Field(&c.Abuse),
+		validation.Field(&c.Challenge),
+		validation.Field(&c.Risk),
+		validation.Field(&c.Device),
+		validation.Field(&c.Session),
+		validation.Field(&c.StepUp),
+		validation.Field(&c.Cookie),
+		validation.Field(&c.Sso),
+		validation.Field(&c.AccessToken),
+		validation.Field(&c.ServiceAccount),
+		validation.Field(&c.Mtls),
+		validation.Field(&c.Dpop),
+		validation.Field(&c.DeviceFlow),
+		validation.Field(&c.TokenExchange),
+		validation.Field(&c.OpaqueTokens),
+		validation.Field(&c.ClaimMappings),
 	)
 }
 
+// Jwt configures the access tokens, the codec picks their format and defaults to JWT
 type Jwt struct {
-	SecretKey string        `yaml:"secretKey"`
-	Issuer    string        `yaml:"issuer"`
-	Subject   string        `yaml:"subject"`
-	Audience  []string      `yaml:"audience"`
-	LifeSpan  time.Duration `yaml:"lifeSpan"`
+	SecretKey string               `yaml:"secretKey"`
+	Issuer    string               `yaml:"issuer"`
+	Subject   string               `yaml:"subject"`
+	Audience  []string             `yaml:"audience"`
+	LifeSpan  time.Duration        `yaml:"lifeSpan"`
+	Codec     constants.TokenCodec `yaml:"codec"`
+	PasetoKey string               `yaml:"pasetoKey"` // Hex encoded 32 byte key of v4.local or Ed25519 seed of v4.public
+	// How permissions are written into the token, empty lists them
+	PermissionEncoding constants.PermissionEncoding `yaml:"permissionEncoding"`
 }
 
 func (j Jwt) Validate() error {
+	paseto := j.Codec == constants.CodecPasetoPublic || j.Codec == constants.CodecPasetoLocal
+
 	return validation.ValidateStruct(&j,
 		validation.Field(&j.Audience, validation.Required, validation.NotNil),
 		validation.Field(&j.LifeSpan, validation.Required),
+		validation.Field(&j.Codec, validation.In(constants.CodecJwt, constants.CodecPasetoPublic, constants.CodecPasetoLocal)),
+		validation.Field(&j.PasetoKey, validation.When(paseto, validation.Required, is.Hexadecimal, validation.Length(64, 64))),
+		validation.Field(&j.PermissionEncoding, validation.In(constants.PermissionEncodingList, constants.PermissionEncodingBitmap, constants.PermissionEncodingRole)),
 	)
 }
 
@@ -62,6 +108,7 @@ type App struct {
 
 func (a App) Validate() error {
 	return validation.ValidateStruct(&a,
+		validation.Field(&a.Login, validation.Required, validation.NotNil),
 		validation.Field(&a.Server, validation.Required, validation.NotNil),
 	)
 }
@@ -83,14 +130,18 @@ func (a Login) Validate() error {
 }
 
 type AuthOtp struct {
-	Length                    int `yaml:"length"`
-	WaitSecondsBeforeOtpRetry int `yaml:"waitSecondsBeforeOtpRetry"`
+	Length                    int           `yaml:"length"`
+	WaitSecondsBeforeOtpRetry int           `yaml:"waitSecondsBeforeOtpRetry"`
+	LifeSpan                  time.Duration `yaml:"lifeSpan"`
+	MaxAttempts               int           `yaml:"maxAttempts"`
 }
 
 func (a AuthOtp) Validate() error {
 	return validation.ValidateStruct(&a,
 		validation.Field(&a.Length, validation.Required, validation.Min(6)),
 		validation.Field(&a.WaitSecondsBeforeOtpRetry, validation.Required, validation.Min(30)),
+		validation.Field(&a.LifeSpan, validation.Required),
+		validation.Field(&a.MaxAttempts, validation.Required, validation.Min(1)),
 	)
 }
 
@@ -98,12 +149,16 @@ type Server struct {
 	Compression bool                  `yaml:"compression"`
 	Environment constants.Environment `yaml:"environment"`
 	Port        int                   `yaml:"port"`
+	TrustProxy  bool                  `yaml:"trustProxy"` // Read client IP from X-Forwarded-For / X-Real-IP
+	// CIDR blocks of the proxies in front of the gateway, forwarded headers are only read from them
+	TrustedProxies []string `yaml:"trustedProxies"`
 }
 
 func (s Server) Validate() error {
 	return validation.ValidateStruct(&s,
 		validation.Field(&s.Environment, validation.Required),
 		validation.Field(&s.Port, validation.Required, validation.Min(1111)),
+		validation.Field(&s.TrustedProxies, validation.When(s.TrustProxy, validation.Required), validation.Each(utils.CIDRValidation())),
 	)
 }
 
@@ -183,3 +238,386 @@ func (c Cache) Validate() error {
 		validation.Field(&c.RetryInterval, validation.Required),
 	)
 }
+
+type RateLimit struct {
+	Enabled bool            `yaml:"enabled"`
+	Prefix  string          `yaml:"prefix"`
+	Rules   []RateLimitRule `yaml:"rules"`
+}
+
+func (r RateLimit) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Prefix, validation.Required),
+		validation.Field(&r.Rules, validation.When(r.Enabled, validation.Required)),
+	)
+}
+
+// RateLimitRule is a token bucket applied to every request matching Method and Path.
+// The bucket holds at most Capacity tokens and is refilled with Rate tokens every Interval.
+type RateLimitRule struct {
+	Method   string                 `yaml:"method"` // Empty matches every method
+	Path     string                 `yaml:"path"`   // "*" matches every path
+	KeyType  constants.RateLimitKey `yaml:"keyType"`
+	Capacity int                    `yaml:"capacity"`
+	Rate     int                    `yaml:"rate"`
+	Interval time.Duration          `yaml:"interval"`
+}
+
+func (r RateLimitRule) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Path, validation.Required),
+		validation.Field(&r.KeyType, validation.Required, validation.In(constants.RateLimitKeyIP, constants.RateLimitKeyUser, constants.RateLimitKeyEmail, constants.RateLimitKeyClient)),
+		validation.Field(&r.Capacity, validation.Required, validation.Min(1)),
+		validation.Field(&r.Rate, validation.Required, validation.Min(1)),
+		validation.Field(&r.Interval, validation.Required),
+	)
+}
+
+func (r RateLimitRule) Matches(method, path string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	return r.Path == constants.RateLimitAnyPath || r.Path == path
+}
+
+// Abuse aggregates signin failures across accounts and source IPs over a rolling window
+type Abuse struct {
+	Enabled       bool           `yaml:"enabled"`
+	Prefix        string         `yaml:"prefix"`
+	Window        time.Duration  `yaml:"window"`
+	BlockDuration time.Duration  `yaml:"blockDuration"`
+	AlertChannel  string         `yaml:"alertChannel"`
+	Source        AbuseThreshold `yaml:"source"`  // Distinct accounts failing from one IP (password spraying)
+	Account       AbuseThreshold `yaml:"account"` // Distinct IPs failing against one account (credential stuffing)
+}
+
+func (a Abuse) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Prefix, validation.Required),
+		validation.Field(&a.Window, validation.Required),
+		validation.Field(&a.BlockDuration, validation.Required),
+		validation.Field(&a.AlertChannel, validation.Required),
+	)
+}
+
+// AbuseThreshold counts at which a response is escalated, zero disables the step.
+// Block only applies to source IPs, accounts are already covered by the login lockout.
+type AbuseThreshold struct {
+	Challenge int `yaml:"challenge"`
+	Block     int `yaml:"block"`
+	Alert     int `yaml:"alert"`
+}
+
+type SigninChallenge struct {
+	Enabled       bool                        `yaml:"enabled"`
+	Provider      constants.ChallengeProvider `yaml:"provider"`
+	AfterFailures int                         `yaml:"afterFailures"` // Recent failures for an account before a challenge is required
+	IPFailures    int                         `yaml:"ipFailures"`    // Failures from an IP within IPWindow before a challenge is required, zero disables it
+	IPWindow      time.Duration               `yaml:"ipWindow"`
+	Pow           *PowChallenge               `yaml:"pow"`
+	Captcha       *CaptchaChallenge           `yaml:"captcha"`
+}
+
+func (c SigninChallenge) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Provider, validation.Skip.When(!c.Enabled), validation.Required, validation.In(constants.ChallengePow, constants.ChallengeRecaptcha, constants.ChallengeHcaptcha)),
+		validation.Field(&c.AfterFailures, validation.Skip.When(!c.Enabled), validation.Required, validation.Min(1)),
+		validation.Field(&c.IPFailures, validation.Skip.When(!c.Enabled), validation.Min(0)),
+		validation.Field(&c.IPWindow, validation.Skip.When(!c.Enabled), validation.When(c.IPFailures > 0, validation.Required)),
+		validation.Field(&c.Pow, validation.Skip.When(!c.Enabled), validation.When(c.Provider == constants.ChallengePow, validation.Required, validation.NotNil)),
+		validation.Field(&c.Captcha, validation.Skip.When(!c.Enabled), validation.When(c.Provider != constants.ChallengePow, validation.Required, validation.NotNil)),
+	)
+}
+
+type PowChallenge struct {
+	SecretKey  string        `yaml:"secretKey"`
+	Difficulty int           `yaml:"difficulty"` // Leading zero bits required in the solution hash
+	LifeSpan   time.Duration `yaml:"lifeSpan"`
+}
+
+func (p PowChallenge) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.SecretKey, validation.Required, validation.Length(16, 0)),
+		validation.Field(&p.Difficulty, validation.Required, validation.Min(8), validation.Max(32)),
+		validation.Field(&p.LifeSpan, validation.Required),
+	)
+}
+
+type CaptchaChallenge struct {
+	SiteKey   string  `yaml:"siteKey"`
+	SecretKey string  `yaml:"secretKey"`
+	VerifyURL string  `yaml:"verifyUrl"` // Defaults to the provider siteverify endpoint
+	MinScore  float64 `yaml:"minScore"`  // reCAPTCHA v3 only, zero disables the check
+}
+
+func (c CaptchaChallenge) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.SiteKey, validation.Required),
+		validation.Field(&c.SecretKey, validation.Required),
+		validation.Field(&c.MinScore, validation.Min(0.0), validation.Max(1.0)),
+	)
+}
+
+// Risk scores each signin from device, network and time signals taken from login history
+type Risk struct {
+	Enabled       bool          `yaml:"enabled"`
+	StepUpScore   int           `yaml:"stepUpScore"`
+	BlockScore    int           `yaml:"blockScore"`
+	HistoryWindow time.Duration `yaml:"historyWindow"` // Login history considered for known devices, networks and hours
+	MinHistory    int           `yaml:"minHistory"`    // Successful logins required before time of day is scored
+	CountryHeader string        `yaml:"countryHeader"` // Set by the edge proxy, e.g. CF-IPCountry
+	AsnHeader     string        `yaml:"asnHeader"`
+	IPDenyList    []string      `yaml:"ipDenyList"`
+	IPAllowList   []string      `yaml:"ipAllowList"`
+	Weights       RiskWeights   `yaml:"weights"`
+}
+
+func (r Risk) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.StepUpScore, validation.Required, validation.Min(1)),
+		validation.Field(&r.BlockScore, validation.Required, validation.Min(r.StepUpScore)),
+		validation.Field(&r.HistoryWindow, validation.Required),
+		validation.Field(&r.IPDenyList, validation.Each(utils.CIDRValidation())),
+		validation.Field(&r.IPAllowList, validation.Each(utils.CIDRValidation())),
+	)
+}
+
+type RiskWeights struct {
+	NewDevice     int `yaml:"newDevice"`
+	MissingDevice int `yaml:"missingDevice"`
+	DeniedIP      int `yaml:"deniedIp"`
+	AllowedIP     int `yaml:"allowedIp"` // Subtracted from the score
+	CountryChange int `yaml:"countryChange"`
+	AsnChange     int `yaml:"asnChange"`
+	UnusualHour   int `yaml:"unusualHour"`
+}
+
+type DeviceTrust struct {
+	Enabled      bool          `yaml:"enabled"`
+	SecretKey    string        `yaml:"secretKey"` // Signs device tokens
+	LifeSpan     time.Duration `yaml:"lifeSpan"`
+	CookieName   string        `yaml:"cookieName"`
+	CookieDomain string        `yaml:"cookieDomain"`
+	CookieSecure bool          `yaml:"cookieSecure"`
+}
+
+func (d DeviceTrust) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.SecretKey, validation.Required, validation.Length(16, 0)),
+		validation.Field(&d.LifeSpan, validation.Required),
+		validation.Field(&d.CookieName, validation.Required),
+	)
+}
+
+// Session keeps a server side record of every signin, referenced by the sid claim
+type SessionStore struct {
+	Enabled          bool                         `yaml:"enabled"`
+	Prefix           string                       `yaml:"prefix"`
+	TouchInterval    time.Duration                `yaml:"touchInterval"`    // Minimum gap between last-seen updates, bounds the idle timeout precision
+	IdleTimeout      time.Duration                `yaml:"idleTimeout"`      // Default for roles without one, zero disables it
+	AbsoluteLifetime time.Duration                `yaml:"absoluteLifetime"` // Default for roles without one
+	MaxSessions      int                          `yaml:"maxSessions"`      // Default for roles without one, zero is unlimited
+	LimitPolicy      constants.SessionLimitPolicy `yaml:"limitPolicy"`      // Default for roles without one
+}
+
+func (s SessionStore) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Prefix, validation.Required),
+		validation.Field(&s.IdleTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&s.AbsoluteLifetime, validation.Required),
+		validation.Field(&s.MaxSessions, validation.Min(0)),
+		validation.Field(&s.LimitPolicy, validation.Required, validation.In(constants.SessionLimitEvictOldest, constants.SessionLimitReject)),
+	)
+}
+
+// StepUp demands a recent strong authentication for sensitive permissions
+type StepUp struct {
+	Enabled bool                  `yaml:"enabled"`
+	Rules   map[string]StepUpRule `yaml:"rules"` // Keyed by permission
+}
+
+func (s StepUp) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Rules, validation.Each()),
+	)
+}
+
+type StepUpRule struct {
+	Acr    constants.Acr `yaml:"acr"`    // Minimum authentication class
+	MaxAge time.Duration `yaml:"maxAge"` // Maximum time since authentication, zero accepts any
+}
+
+func (s StepUpRule) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Acr, validation.Required, validation.In(constants.AcrSingleFactor, constants.AcrMultiFactor)),
+		validation.Field(&s.MaxAge, validation.Min(time.Duration(0))),
+	)
+}
+
+// CookieSession lets browsers keep tokens in HttpOnly cookies instead of script readable storage
+type CookieSession struct {
+	Enabled     bool   `yaml:"enabled"`
+	SecretKey   string `yaml:"secretKey"` // Signs CSRF tokens
+	AccessName  string `yaml:"accessName"`
+	RefreshName string `yaml:"refreshName"`
+	CsrfName    string `yaml:"csrfName"` // Readable by scripts, echoed back in the X-CSRF-Token header
+	Domain      string `yaml:"domain"`
+	Secure      bool   `yaml:"secure"`
+	SameSite    string `yaml:"sameSite"` // lax, strict or none
+}
+
+func (c CookieSession) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.SecretKey, validation.Required, validation.Length(16, 0)),
+		validation.Field(&c.AccessName, validation.Required),
+		validation.Field(&c.RefreshName, validation.Required),
+		validation.Field(&c.CsrfName, validation.Required),
+		validation.Field(&c.SameSite, validation.Required, validation.In("lax", "strict", "none")),
+	)
+}
+
+// Sso shares the gateway session across applications through a cookie on the gateway domain
+type Sso struct {
+	Enabled      bool          `yaml:"enabled"`
+	SecretKey    string        `yaml:"secretKey"` // Signs the SSO cookie
+	CookieName   string        `yaml:"cookieName"`
+	CookieDomain string        `yaml:"cookieDomain"`
+	CookieSecure bool          `yaml:"cookieSecure"`
+	LoginURL     string        `yaml:"loginUrl"` // Login page, receives the authorization request to resume in return_to
+	CodeLifeSpan time.Duration `yaml:"codeLifeSpan"`
+	// Back-channel logout delivery, each retry waits twice as long as the previous one
+	LogoutRetries    int           `yaml:"logoutRetries"`
+	LogoutRetryDelay time.Duration `yaml:"logoutRetryDelay"`
+	// Hex encoded Ed25519 seed signing logout tokens, clients verify them with the published JWKS
+	LogoutSigningKey string `yaml:"logoutSigningKey"`
+}
+
+func (s Sso) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.SecretKey, validation.Required, validation.Length(16, 0)),
+		validation.Field(&s.CookieName, validation.Required),
+		validation.Field(&s.LoginURL, validation.Required),
+		validation.Field(&s.CodeLifeSpan, validation.Required),
+		validation.Field(&s.LogoutRetries, validation.Min(0)),
+		validation.Field(&s.LogoutRetryDelay, validation.When(s.LogoutRetries > 0, validation.Required)),
+		validation.Field(&s.LogoutSigningKey, validation.When(s.Enabled, validation.Required), is.Hexadecimal, validation.Length(64, 64)),
+	)
+}
+
+// AccessTokens configures personal access tokens
+type AccessTokens struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxLifeSpan   time.Duration `yaml:"maxLifeSpan"`
+	MaxPerUser    int           `yaml:"maxPerUser"`    // Active tokens per user, 0 is unlimited
+	TouchInterval time.Duration `yaml:"touchInterval"` // Minimum time between last used updates
+}
+
+func (a AccessTokens) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.MaxLifeSpan, validation.Required),
+		validation.Field(&a.MaxPerUser, validation.Min(0)),
+	)
+}
+
+// ServiceAccounts configures machine identities and their signed assertions
+type ServiceAccounts struct {
+	Enabled         bool          `yaml:"enabled"`
+	Audience        string        `yaml:"audience"`        // Expected aud of assertions, usually the token endpoint URL
+	MaxAssertionAge time.Duration `yaml:"maxAssertionAge"` // Longest accepted exp - iat of an assertion
+	TokenLifeSpan   time.Duration `yaml:"tokenLifeSpan"`
+}
+
+func (s ServiceAccounts) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Audience, validation.Required),
+		validation.Field(&s.MaxAssertionAge, validation.Required),
+		validation.Field(&s.TokenLifeSpan, validation.Required),
+	)
+}
+
+// Mtls accepts client certificates issued by the configured CAs. The gateway terminates TLS
+// itself, unless CertHeader is set and a trusted proxy forwards the certificate instead.
+type Mtls struct {
+	Enabled           bool     `yaml:"enabled"`
+	CertFile          string   `yaml:"certFile"`
+	KeyFile           string   `yaml:"keyFile"`
+	ClientCAFiles     []string `yaml:"clientCaFiles"`
+	RequireClientCert bool     `yaml:"requireClientCert"` // Otherwise the handshake succeeds without a certificate
+	CertHeader        string   `yaml:"certHeader"`        // URL escaped PEM set by the proxy, only read from the trusted proxies
+	BindTokens        bool     `yaml:"bindTokens"`        // Bind tokens issued over mTLS to the certificate, see RFC 8705
+}
+
+func (m Mtls) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.CertFile, validation.When(m.CertHeader == "", validation.Required)),
+		validation.Field(&m.KeyFile, validation.When(m.CertHeader == "", validation.Required)),
+		validation.Field(&m.ClientCAFiles, validation.Required),
+	)
+}
+
+// Dpop accepts RFC 9449 proofs of possession and binds tokens to the proof key
+type Dpop struct {
+	Enabled     bool          `yaml:"enabled"`
+	BaseURL     string        `yaml:"baseUrl"`     // Scheme and host clients reach the gateway at, htu must start with it
+	MaxProofAge time.Duration `yaml:"maxProofAge"` // Largest accepted distance between iat and now
+}
+
+func (d Dpop) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.BaseURL, validation.Required),
+		validation.Field(&d.MaxProofAge, validation.Required),
+	)
+}
+
+// DeviceFlow configures the device authorization grant for devices without a browser
+type DeviceFlow struct {
+	Enabled         bool          `yaml:"enabled"`
+	VerificationURI string        `yaml:"verificationUri"` // Page where users enter the user code
+	CodeLifeSpan    time.Duration `yaml:"codeLifeSpan"`
+	PollInterval    time.Duration `yaml:"pollInterval"`
+}
+
+func (d DeviceFlow) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.VerificationURI, validation.Required),
+		validation.Field(&d.CodeLifeSpan, validation.Required),
+		validation.Field(&d.PollInterval, validation.Required, validation.Min(time.Second)),
+	)
+}
+
+// TokenExchange configures RFC 8693 token exchange, used to narrow tokens and to impersonate users
+type TokenExchange struct {
+	Enabled               bool          `yaml:"enabled"`
+	ImpersonationLifeSpan time.Duration `yaml:"impersonationLifeSpan"`
+	RequireReason         bool          `yaml:"requireReason"` // Impersonation requests must state why
+}
+
+func (t TokenExchange) Validate() error {
+	return validation.ValidateStruct(&t,
+		validation.Field(&t.ImpersonationLifeSpan, validation.Required),
+	)
+}
+
+// OpaqueTokens configures random reference tokens whose claims live in the cache. Clients opt in
+// with their token format, tokens for one of the audiences are always opaque.
+type OpaqueTokens struct {
+	Enabled   bool     `yaml:"enabled"`
+	Audiences []string `yaml:"audiences"`
+}
+
+func (o OpaqueTokens) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Audiences, validation.Each(validation.Required)),
+	)
+}
+
+// ClaimMappings adds claims to the tokens of an audience, a client mapping overrides them per claim
+type ClaimMappings struct {
+	Audiences map[string]ClaimMapping `yaml:"audiences"`
+}
+
+func (c ClaimMappings) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Audiences),
+	)
+}
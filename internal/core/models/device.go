@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TrustedDevice lets a user skip the second factor from a device they chose to remember
+type TrustedDevice struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	UserID     int       `json:"user_id" gorm:"index;not null"`
+	DeviceHash string    `json:"-" gorm:"not null"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -10,13 +10,20 @@ import (
 )
 
 type LoginHistory struct {
-	ID         int              `json:"id"`
-	UserID     int              `json:"user_id"`
-	Status     constants.Status `json:"status"`
-	Token      string           `json:"token"`
-	Reason     string           `json:"reason"`
-	Permission []string         `json:"permissions"`
-	LoginAt    time.Time        `json:"login_at"`
+	ID          int              `json:"id"`
+	UserID      int              `json:"user_id"`
+	Status      constants.Status `json:"status"`
+	Token       string           `json:"token"`
+	Reason      string           `json:"reason"`
+	Permission  []string         `json:"permissions" gorm:"type:text[]"`
+	IP          string           `json:"ip"`
+	UserAgent   string           `json:"user_agent"`
+	DeviceHash  string           `json:"device_hash"`
+	Country     string           `json:"country"`
+	Asn         string           `json:"asn"`
+	RiskScore   int              `json:"risk_score"`
+	RiskReasons []string         `json:"risk_reasons" gorm:"type:text[]"`
+	LoginAt     time.Time        `json:"login_at"`
 }
 
 type LoginRequest struct {
@@ -27,12 +34,16 @@ type LoginRequest struct {
 	Password        string `json:"password,omitempty"`
 	MobileNumber    int64  `json:"mobile_number,omitempty"`
 	DeviceHash      string `json:"device_hash,omitempty"`
+	ChallengeToken  string `json:"challenge_token,omitempty"`
+	DeviceToken     string `json:"device_token,omitempty"` // Falls back to the device cookie
 }
 
 func (l *LoginRequest) Sanitize() {
 	l.Email = utils.SanitizeLower(l.Email)
 	l.Password = utils.Sanitize(l.Password)
 	l.DeviceHash = utils.Sanitize(l.DeviceHash)
+	l.ChallengeToken = utils.Sanitize(l.ChallengeToken)
+	l.DeviceToken = utils.Sanitize(l.DeviceToken)
 }
 
 func (l LoginRequest) Validate() error {
@@ -54,3 +65,87 @@ func (l LoginRequest) Validate() error {
 		),
 	)
 }
+
+// MfaChallenge is a signin that passed the password check and waits for a second factor
+type MfaChallenge struct {
+	Token       string    `json:"token"`
+	UserID      int       `json:"user_id"`
+	CodeHash    string    `json:"code_hash"`
+	LastSentAt  time.Time `json:"last_sent_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	DeviceHash  string    `json:"device_hash"`
+	Country     string    `json:"country"`
+	Asn         string    `json:"asn"`
+	RiskScore   int       `json:"risk_score"`
+	RiskReasons []string  `json:"risk_reasons"`
+	StepUp      bool      `json:"step_up"`    // Re-authenticates a signed in user instead of completing a signin
+	SessionID   string    `json:"session_id"` // Session being stepped up
+}
+
+// StepUpRequired tells the client how strongly it must authenticate again
+type StepUpRequired struct {
+	AcrValues constants.Acr `json:"acr_values"`
+	MaxAge    int           `json:"max_age,omitempty"` // Seconds
+}
+
+// MfaRequired is returned when signin needs a second factor
+type MfaRequired struct {
+	MfaToken  string    `json:"mfa_token"`
+	Methods   []string  `json:"methods"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type OtpRequest struct {
+	MfaToken string `json:"mfa_token"`
+}
+
+func (o *OtpRequest) Sanitize() {
+	o.MfaToken = utils.Sanitize(o.MfaToken)
+}
+
+func (o OtpRequest) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.MfaToken, validation.Required),
+	)
+}
+
+type VerifyRequest struct {
+	MfaToken       string `json:"mfa_token"`
+	Code           string `json:"code"`
+	RememberDevice bool   `json:"remember_device"`
+}
+
+func (v *VerifyRequest) Sanitize() {
+	v.MfaToken = utils.Sanitize(v.MfaToken)
+	v.Code = utils.Sanitize(v.Code)
+}
+
+func (v VerifyRequest) Validate() error {
+	return validation.ValidateStruct(&v,
+		validation.Field(&v.MfaToken, validation.Required),
+		validation.Field(&v.Code, validation.Required, is.Digit),
+	)
+}
+
+// VerifyResponse is the payload of a completed second factor
+type VerifyResponse struct {
+	User         *User     `json:"user"`
+	DeviceToken  string    `json:"device_token,omitempty"`
+	TrustedUntil time.Time `json:"trusted_until,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshRequest) Sanitize() {
+	r.RefreshToken = utils.Sanitize(r.RefreshToken)
+}
+
+func (r RefreshRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.RefreshToken, validation.Required),
+	)
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// AuthorizationCode is the single use code handed to a client after authorization
+type AuthorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	SessionID     string    `json:"session_id"`
+	UserID        int       `json:"user_id"`
+	CodeChallenge string    `json:"code_challenge,omitempty"`
+	AuthTime      time.Time `json:"auth_time"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// TokenResponse follows the OAuth 2.0 token endpoint format
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	// Set by token exchange, see RFC 8693
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// Introspection describes a token to a resource server, see RFC 7662. Inactive tokens only carry active.
+type Introspection struct {
+	Active           bool              `json:"active"`
+	Scope            string            `json:"scope,omitempty"`
+	TokenType        string            `json:"token_type,omitempty"`
+	Subject          string            `json:"sub,omitempty"`
+	Audience         []string          `json:"aud,omitempty"`
+	Issuer           string            `json:"iss,omitempty"`
+	ID               string            `json:"jti,omitempty"`
+	ExpiresAt        int64             `json:"exp,omitempty"`
+	IssuedAt         int64             `json:"iat,omitempty"`
+	UserID           int               `json:"user_id,omitempty"`
+	Role             constants.Roles   `json:"role,omitempty"`
+	SessionID        string            `json:"sid,omitempty"`
+	ServiceAccountID string            `json:"service_account_id,omitempty"`
+	Cnf              *Confirmation     `json:"cnf,omitempty"`
+	Act              *Actor            `json:"act,omitempty"`
+	Custom           map[string]string `json:"-"`
+}
+
+// introspectionClaims has the fields of Introspection without its JSON method
+type introspectionClaims Introspection
+
+func (i Introspection) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(introspectionClaims(i))
+	if err != nil {
+		return nil, err
+	}
+	return withCustomClaims(data, i.Custom)
+}
+
+// JwkSet publishes the public keys of the tokens clients verify themselves, see RFC 7517
+type JwkSet struct {
+	Keys []Jwk `json:"keys"`
+}
+
+type Jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// OAuthError follows the OAuth 2.0 error response format
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// DeviceAuthorization is a device authorization request, kept in the cache under its device code
+// until the device fetches its token or the request expires
+type DeviceAuthorization struct {
+	ClientID  string                 `json:"client_id"`
+	UserCode  string                 `json:"user_code"`
+	Status    constants.DeviceStatus `json:"status"`
+	SessionID string                 `json:"session_id,omitempty"` // Session of the user who approved
+	UserID    int                    `json:"user_id,omitempty"`
+	Interval  int                    `json:"interval"` // Seconds the device must wait between polls at first
+	ExpiresAt time.Time              `json:"expires_at"`
+}
+
+// DevicePoll paces the polls of a device. It is stored apart from the authorization so a poll
+// never overwrites the answer of the user.
+type DevicePoll struct {
+	Interval     int       `json:"interval"`
+	LastPolledAt time.Time `json:"last_polled_at"`
+}
+
+// DeviceAuthorizationResponse follows the RFC 8628 device authorization response format
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceRequest is shown to the user before they approve it
+type DeviceRequest struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	UserCode   string    `json:"user_code"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type DeviceVerifyRequest struct {
+	UserCode string `json:"user_code"`
+	Approve  bool   `json:"approve"`
+}
+
+func (d *DeviceVerifyRequest) Sanitize() {
+	d.UserCode = NormalizeUserCode(d.UserCode)
+}
+
+func (d DeviceVerifyRequest) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.UserCode, validation.Required, validation.Length(8, 8)),
+	)
+}
+
+// NormalizeUserCode drops the separators and case users may type a user code with
+func NormalizeUserCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// Impersonation records a token issued to staff acting as a user
+type Impersonation struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	ActorID     int       `json:"actor_id"`
+	UserID      int       `json:"user_id"`
+	TokenID     string    `json:"token_id"` // jti of the issued token
+	Permissions []string  `json:"permissions" gorm:"type:text[]"`
+	Reason      string    `json:"reason,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RateLimitResult struct {
+	Allowed    bool          `json:"allowed"`
+	Limit      int           `json:"limit"`
+	Remaining  int           `json:"remaining"`
+	RetryAfter time.Duration `json:"retry_after"` // Wait before the next request is allowed, zero when allowed
+	Reset      time.Duration `json:"reset"`       // Time until the bucket is full again
+}
@@ -1,14 +1,15 @@
 package models
 
 type Response struct {
-	StatusCode  int      `json:"status_code"`
-	Status      bool     `json:"status"`
-	RequestID   string   `json:"request_id"`
-	Message     string   `json:"message"`
-	Token       string   `json:"token,omitempty"`
-	Permissions []string `json:"permissions,omitempty"`
-	Error       *Error   `json:"error,omitempty"`
-	Payload     any      `json:"payload,omitempty"`
+	StatusCode   int      `json:"status_code"`
+	Status       bool     `json:"status"`
+	RequestID    string   `json:"request_id"`
+	Message      string   `json:"message"`
+	Token        string   `json:"token,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	Permissions  []string `json:"permissions,omitempty"`
+	Error        *Error   `json:"error,omitempty"`
+	Payload      any      `json:"payload,omitempty"`
 }
 
 type Error struct {
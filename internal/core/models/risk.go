@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+type RiskInput struct {
+	User       *User
+	IP         string
+	UserAgent  string
+	DeviceHash string
+	Country    string
+	Asn        string
+	At         time.Time
+}
+
+type RiskAssessment struct {
+	Score    int                    `json:"score"`
+	Reasons  []string               `json:"reasons,omitempty"`
+	Decision constants.RiskDecision `json:"decision"`
+}
+
+func (r *RiskAssessment) Add(weight int, reason string) {
+	if weight == 0 {
+		return
+	}
+	r.Score += weight
+	r.Reasons = append(r.Reasons, reason)
+}
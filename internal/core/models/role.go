@@ -1,6 +1,8 @@
 package models
 
 import (
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
@@ -8,24 +10,38 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
+var roleIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
 type Role struct {
 	ID          constants.Roles  `json:"id" gorm:"primaryKey;autoIncrement:false"`
 	Description string           `json:"description" gorm:"type:varchar(255)"`
 	Permissions []string         `json:"permissions" gorm:"type:text[]"`
 	Status      constants.Status `json:"status" gorm:"type:varchar(20);not null"`
-	CreatedBy   int              `json:"created_by,omitempty"`
-	UpdatedBy   int              `json:"updated_by,omitempty"`
-	CreatedAt   time.Time        `json:"created_at,omitempty"`
-	UpdatedAt   time.Time        `json:"updated_at,omitempty"`
+	// Roles whose permissions the role inherits, transitively
+	Parents []string `json:"parents,omitempty" gorm:"type:text[]"`
+	// System roles are relied on by the gateway, they cannot be deleted or deactivated
+	IsSystem bool `json:"is_system" gorm:"not null;default:false"`
+	// Session limits of the role, zero falls back to the configured defaults
+	IdleTimeout      time.Duration                `json:"idle_timeout,omitempty"`
+	AbsoluteLifetime time.Duration                `json:"absolute_lifetime,omitempty"`
+	MaxSessions      int                          `json:"max_sessions,omitempty"`
+	SessionPolicy    constants.SessionLimitPolicy `json:"session_policy,omitempty"`
+	CreatedBy        int                          `json:"created_by,omitempty"`
+	UpdatedBy        int                          `json:"updated_by,omitempty"`
+	CreatedAt        time.Time                    `json:"created_at,omitempty"`
+	UpdatedAt        time.Time                    `json:"updated_at,omitempty"`
 }
 
 func (r *Role) Sanitize(operation constants.Operations, userID int) {
 	now := time.Now()
+	r.ID = constants.Roles(utils.SanitizeLower(r.ID.String()))
 	r.Description = utils.Sanitize(r.Description)
 	r.Permissions = utils.SanitizeLowerSlice(r.Permissions)
+	r.Parents = utils.SanitizeLowerSlice(r.Parents)
 
 	if operation == constants.Create {
 		r.Status = constants.StatusActive
+		r.IsSystem = slices.Contains(constants.SystemRoles, r.ID)
 		r.CreatedBy = userID
 		r.CreatedAt = now
 	}
@@ -36,14 +52,32 @@ func (r *Role) Sanitize(operation constants.Operations, userID int) {
 
 func (r Role) Validate() error {
 	return validation.ValidateStruct(&r,
-		// validation.Field(&r.ID, validation.Required, validation.Length(3, 20), validation.Match(regexp.MustCompile(`^[a-z_]+$`)).Error("ID must contain only lowercase letters and underscores")),
-		validation.Field(&r.ID, validation.Required, validation.In(constants.RoleAnonymousUser, constants.RoleSessionUser, constants.RoleSystemAdmin, constants.Roleadmin, constants.Roleuser)),
-		validation.Field(&r.Permissions, validation.Required, validation.Each(validation.Length(3, 30))),
+		validation.Field(&r.ID, validation.Required, validation.Length(3, 30), validation.Match(roleIDPattern).Error("ID must start with a lowercase letter and contain only lowercase letters, digits and underscores")),
+		// A role with parents may only grant what it inherits
+		validation.Field(&r.Permissions, validation.When(len(r.Parents) == 0, validation.Required), validation.Each(validation.Length(3, 30))),
+		validation.Field(&r.Parents, validation.Each(validation.Length(3, 30), validation.Match(roleIDPattern))),
 		validation.Field(&r.Description, validation.Required, validation.Length(3, 200)),
 		validation.Field(&r.Status, validation.Required, validation.In(constants.StatusActive, constants.StatusInactive)),
+		validation.Field(&r.IdleTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&r.AbsoluteLifetime, validation.Min(time.Duration(0))),
+		validation.Field(&r.MaxSessions, validation.Min(0)),
+		validation.Field(&r.SessionPolicy, validation.In(constants.SessionLimitEvictOldest, constants.SessionLimitReject)),
 	)
 }
 
+// EffectivePermissions are the permissions granted by a role, its own and those it inherits
+type EffectivePermissions struct {
+	Role        constants.Roles       `json:"role"`
+	Ancestors   []constants.Roles     `json:"ancestors"` // Active roles inherited from, nearest first
+	Permissions []EffectivePermission `json:"permissions"`
+}
+
+// EffectivePermission lists the roles granting the permission, the role itself before its ancestors
+type EffectivePermission struct {
+	ID      string            `json:"id"`
+	Sources []constants.Roles `json:"sources"`
+}
+
 // We can use this if we need to allow all type of status
 // func validateStatus(value interface{}) error {
 // 	if s, ok := value.(constants.Status); ok && s.IsValid() {
@@ -51,11 +85,3 @@ func (r Role) Validate() error {
 // 	}
 // 	return errors.New("invalid status")
 // }
-
-// We can use this if we need to allow all type of roles
-// func validateRoles(value interface{}) error {
-// 	if s, ok := value.(constants.Roles); ok && s.IsValid() {
-// 		return nil
-// 	}
-// 	return errors.New("invalid status")
-// }
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// ServiceAccount is a machine identity. It has no password and authenticates with signed
+// assertions of one of its registered keys.
+type ServiceAccount struct {
+	ID          string           `json:"id" gorm:"primaryKey"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Team        string           `json:"team" gorm:"index"` // Owning team
+	Roles       []string         `json:"roles" gorm:"type:text[]"`
+	Status      constants.Status `json:"status"`
+	CreatedBy   int              `json:"created_by,omitempty"`
+	UpdatedBy   int              `json:"updated_by,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+type ServiceAccountRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Team        string   `json:"team"`
+	Roles       []string `json:"roles"`
+}
+
+func (s *ServiceAccountRequest) Sanitize() {
+	s.Name = utils.Sanitize(s.Name)
+	s.Description = utils.Sanitize(s.Description)
+	s.Team = utils.SanitizeLower(s.Team)
+	s.Roles = utils.SanitizeLowerSlice(s.Roles)
+}
+
+func (s ServiceAccountRequest) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Name, validation.Required, validation.Length(3, 100)),
+		validation.Field(&s.Description, validation.Length(0, 200)),
+		validation.Field(&s.Team, validation.Required, validation.Length(2, 50)),
+		validation.Field(&s.Roles, validation.Required),
+	)
+}
+
+type ServiceAccountRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+func (s *ServiceAccountRolesRequest) Sanitize() {
+	s.Roles = utils.SanitizeLowerSlice(s.Roles)
+}
+
+func (s ServiceAccountRolesRequest) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Roles, validation.Required),
+	)
+}
+
+// ServiceAccountKey is a public key the service account signs its assertions with
+type ServiceAccountKey struct {
+	ID               string     `json:"kid" gorm:"primaryKey"`
+	ServiceAccountID string     `json:"service_account_id" gorm:"index;not null"`
+	PublicKey        string     `json:"public_key"` // PEM encoded
+	Algorithm        string     `json:"alg"`        // Derived from the key type, assertions must use it
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ServiceAccountKeyRequest struct {
+	PublicKey string     `json:"public_key"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s ServiceAccountKeyRequest) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.PublicKey, validation.Required, validation.Length(0, 8192)),
+		validation.Field(&s.ExpiresAt, validation.When(s.ExpiresAt != nil, validation.Min(time.Now()))),
+	)
+}
+
+// ServiceAccountAudit is an entry of the audit trail of a service account
+type ServiceAccountAudit struct {
+	ID               int                            `json:"id" gorm:"primaryKey"`
+	ServiceAccountID string                         `json:"service_account_id" gorm:"index;not null"`
+	Action           constants.ServiceAccountAction `json:"action"`
+	ActorID          int                            `json:"actor_id,omitempty"` // Administrator behind the change, zero for the account itself
+	KeyID            string                         `json:"kid,omitempty"`
+	IP               string                         `json:"ip,omitempty"`
+	Detail           string                         `json:"detail,omitempty"`
+	CreatedAt        time.Time                      `json:"created_at"`
+}
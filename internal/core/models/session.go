@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+// Session is the server side record of a signin, tokens reference it through the sid claim
+type Session struct {
+	ID          string        `json:"id" gorm:"primaryKey"`
+	UserID      int           `json:"user_id" gorm:"index;not null"`
+	DeviceHash  string        `json:"-"`
+	RefreshHash string        `json:"-"` // Hash of the current refresh token secret
+	IP          string        `json:"ip"`
+	UserAgent   string        `json:"user_agent"`
+	CreatedAt   time.Time     `json:"created_at"`
+	LastSeenAt  time.Time     `json:"last_seen_at"`
+	IdleTimeout time.Duration `json:"-"` // Zero never idles out
+	Acr         constants.Acr `json:"acr"`
+	Amr         []string      `json:"amr" gorm:"type:text[]"`
+	AuthTime    time.Time     `json:"auth_time"`                  // Last time the user authenticated on this session
+	Clients     []string      `json:"clients" gorm:"type:text[]"` // Applications signed in through this session
+	ExpiresAt   time.Time     `json:"expires_at"`                 // Absolute end of the session
+	Current     bool          `json:"current" gorm:"-"`
+}
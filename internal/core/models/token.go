@@ -1,13 +1,99 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type Token struct {
-	UserID      int                 `json:"user_id"`
-	Role        constants.Roles     `json:"role"`
-	Permissions map[string]struct{} `json:"permission"`
+	UserID           int                 `json:"user_id"`
+	Role             constants.Roles     `json:"role"`
+	Permissions      map[string]struct{} `json:"permission,omitempty"`
+	PermissionBitmap *PermissionBitmap   `json:"pbm,omitempty"` // Compact form of the permissions, the rest stay in permission
+	RolePermissions  bool                `json:"rpm,omitempty"` // The permissions are those of the role, resolved by the gateway
+	SessionID        string              `json:"sid,omitempty"`
+	Acr              constants.Acr       `json:"acr,omitempty"`
+	Amr              []string            `json:"amr,omitempty"`
+	AuthTime         *jwt.NumericDate    `json:"auth_time,omitempty"`
+	AccessTokenID    string              `json:"-"` // Set when the request authenticated with a personal access token
+	ServiceAccountID string              `json:"service_account_id,omitempty"`
+	Cnf              *Confirmation       `json:"cnf,omitempty"`
+	Act              *Actor              `json:"act,omitempty"`
+	Custom           map[string]string   `json:"-"` // Claims of the claim mappings, see ClaimMapping
+	jwt.RegisteredClaims
+}
+
+// tokenClaims has the fields of Token without its JSON methods
+type tokenClaims Token
+
+func (t Token) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(tokenClaims(t))
+	if err != nil {
+		return nil, err
+	}
+	return withCustomClaims(data, t.Custom)
+}
+
+func (t *Token) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*tokenClaims)(t)); err != nil {
+		return err
+	}
+
+	custom, err := customClaims(data)
+	if err != nil {
+		return err
+	}
+	t.Custom = custom
+	return nil
+}
+
+func (t *Token) HasPermission(permission string) bool {
+	_, found := t.Permissions[permission]
+	return found
+}
+
+// PermissionBitmap sets bit i, counting from the low bit of the first byte, for permission i of the index
+type PermissionBitmap struct {
+	Version string `json:"v"`
+	Bits    string `json:"b"` // Unpadded base64url
+}
+
+// PermissionIndex numbers the permissions for bitmaps, every change of the list is a new version
+type PermissionIndex struct {
+	Version     string   `json:"version"`
+	Permissions []string `json:"permissions"`
+}
+
+// LogoutToken tells a client that a session ended, see OpenID Connect Back-Channel Logout
+type LogoutToken struct {
+	SessionID string              `json:"sid,omitempty"`
+	Events    map[string]struct{} `json:"events"`
 	jwt.RegisteredClaims
 }
+
+// TokenOptions carries optional claims of a generated token
+type TokenOptions struct {
+	SessionID        string
+	NotAfter         time.Time // Caps the expiry, zero keeps the configured life span
+	Acr              constants.Acr
+	Amr              []string
+	AuthTime         time.Time // When the user last authenticated, zero omits the claim
+	Audience         []string  // Overrides the configured audience
+	ServiceAccountID string
+	Confirmation     *Confirmation         // Binds the token to a key its holder must prove possession of
+	Actor            *Actor                // Who acts on behalf of the user, see RFC 8693
+	Format           constants.TokenFormat // Empty issues a JWT unless the audience asks for an opaque token
+	ClaimMapping     ClaimMapping          // Extra claims of the client, on top of those of the audiences
+}
+
+// Actor identifies the party acting on behalf of the subject of a token, the nested actor is the
+// one that acted before it in a chain of exchanges
+type Actor struct {
+	Subject          string `json:"sub"`
+	UserID           int    `json:"user_id,omitempty"`
+	ServiceAccountID string `json:"service_account_id,omitempty"`
+	Act              *Actor `json:"act,omitempty"`
+}
@@ -10,17 +10,21 @@ import (
 )
 
 type User struct {
-	ID           int              `json:"id"`
-	Mobile       int              `json:"mobile"`
-	Role         constants.Roles  `json:"role"`
-	Permissions  []string         `json:"permissions"`
-	UserName     string           `json:"user_name"`
-	Name         string           `json:"name"`
-	Email        string           `json:"email"`
-	Password     string           `json:"password"`
-	Status       constants.Status `json:"status,omitempty"`
-	LockoutUntil time.Time        `json:"lockout_until,omitempty"`
-	SignupAt     time.Time        `json:"signup_at"`
+	ID                int               `json:"id"`
+	Mobile            int               `json:"mobile"`
+	Role              constants.Roles   `json:"role"`
+	Permissions       []string          `json:"permissions"`
+	UserName          string            `json:"user_name"`
+	Name              string            `json:"name"`
+	Email             string            `json:"email"`
+	Password          string            `json:"password"`
+	Status            constants.Status  `json:"status,omitempty"`
+	MfaEnabled        bool              `json:"mfa_enabled"`
+	LockoutUntil      time.Time         `json:"lockout_until,omitempty"`
+	PasswordChangedAt time.Time         `json:"password_changed_at,omitempty"`
+	SignupAt          time.Time         `json:"signup_at"`
+	TenantID          string            `json:"tenant_id,omitempty"`
+	Attributes        map[string]string `json:"attributes,omitempty" gorm:"serializer:json"` // Custom attributes, available to claim mappings
 }
 
 func (u *User) Sanitize() {
@@ -37,3 +41,20 @@ func (u User) Validate() error {
 		validation.Field(&u.Password, validation.Required, utils.PasswordStrengthValidation(8, 20)),
 	)
 }
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (c *ChangePasswordRequest) Sanitize() {
+	c.CurrentPassword = utils.Sanitize(c.CurrentPassword)
+	c.NewPassword = utils.Sanitize(c.NewPassword)
+}
+
+func (c ChangePasswordRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.CurrentPassword, validation.Required),
+		validation.Field(&c.NewPassword, validation.Required, utils.PasswordStrengthValidation(8, 20), validation.NotIn(c.CurrentPassword).Error("New password must differ from the current one")),
+	)
+}
@@ -2,7 +2,10 @@ package egress
 
 import (
 	"context"
+	"time"
 
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -10,3 +13,35 @@ type CacheConnectionPorts interface {
 	Connect(ctx context.Context) (*redis.Client, error)
 	Close() error
 }
+
+type CacheRepositoryPorts interface {
+	Get(ctx context.Context, key string, response any) (string, error)
+	GetDel(ctx context.Context, key string, response any) error
+	Add(ctx context.Context, key string, value any, ttl time.Duration, strategy constants.CacheStrategy) error
+	Delete(ctx context.Context, key string) error
+	Incr(ctx context.Context, key string, ttl time.Duration) (int, error)
+}
+
+type RateLimitRepositoryPorts interface {
+	Take(ctx context.Context, key string, rule *models.RateLimitRule) (*models.RateLimitResult, error)
+}
+
+type ChallengeRepositoryPorts interface {
+	Consume(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
+
+// ReplayRepositoryPorts remembers one time identifiers such as assertion and proof jtis
+type ReplayRepositoryPorts interface {
+	Consume(ctx context.Context, scope, id string, ttl time.Duration) (bool, error)
+}
+
+type AbuseRepositoryPorts interface {
+	RecordFailure(ctx context.Context, source, account string, window time.Duration) (sourceAccounts, accountSources int, err error)
+	Counts(ctx context.Context, source, account string, window time.Duration) (sourceAccounts, accountSources int, err error)
+	Block(ctx context.Context, blocked *models.BlockedSource) error
+	GetBlock(ctx context.Context, source string) (*models.BlockedSource, error)
+	ListBlocked(ctx context.Context) ([]models.BlockedSource, error)
+	Unblock(ctx context.Context, source string) error
+	MarkAlerted(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	PublishAlert(ctx context.Context, channel string, event *models.AlertEvent) error
+}
@@ -0,0 +1,12 @@
+package egress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+)
+
+type ChallengePorts interface {
+	Issue(ctx context.Context) (*models.Challenge, error)
+	Verify(ctx context.Context, token, remoteIP string) error
+}
@@ -0,0 +1,9 @@
+package egress
+
+import "github.com/golang-jwt/jwt/v4"
+
+// TokenCodecPorts turns claims into a token and back, Decode also validates the registered claims
+type TokenCodecPorts interface {
+	Encode(claims jwt.Claims) (string, error)
+	Decode(token string, claims jwt.Claims) error
+}
@@ -18,6 +18,7 @@ type DatabaseConnectionPorts interface {
 type RoleRepositoryPorts interface {
 	Add(ctx context.Context, role *models.Role) error
 	GetByID(ctx context.Context, id constants.Roles) (*models.Role, error)
+	Update(ctx context.Context, role *models.Role) error
 	DeleteByID(ctx context.Context, id constants.Roles) error
 	GetByIDs(ctx context.Context, ids []constants.Roles) ([]models.Role, error)
 	GetRolesWithoutPagination(ctx context.Context) ([]models.Role, error)
@@ -28,6 +29,7 @@ type UserRepositoryPorts interface {
 	GetByID(ctx context.Context, id int) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	LockByID(ctx context.Context, id int, lockout_until time.Time) error
+	UpdatePassword(ctx context.Context, id int, password string, changedAt time.Time) error
 }
 
 type PermissionRepositoryPorts interface {
@@ -37,3 +39,68 @@ type PermissionRepositoryPorts interface {
 	GetByIDs(ctx context.Context, ids []string) ([]ingressModel.Permission, error)
 	GetPermissionWithoutPagination(ctx context.Context) ([]ingressModel.Permission, error)
 }
+
+type SessionRepositoryPorts interface {
+	Add(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id string) (*models.Session, error)
+	GetByUserID(ctx context.Context, userID int) ([]models.Session, error)
+	Touch(ctx context.Context, id string, lastSeenAt time.Time) error
+	RotateRefreshHash(ctx context.Context, id, currentHash, refreshHash string) error
+	SetAuthentication(ctx context.Context, id string, acr constants.Acr, amr []string, authTime time.Time) error
+	AddClient(ctx context.Context, id, clientID string) error
+	Delete(ctx context.Context, id string, userID int) error
+	DeleteByUserID(ctx context.Context, userID int, exceptID string) error
+}
+
+type ClientRepositoryPorts interface {
+	Add(ctx context.Context, client *models.Client) error
+	GetByID(ctx context.Context, id string) (*models.Client, error)
+	List(ctx context.Context) ([]models.Client, error)
+	DeleteByID(ctx context.Context, id string) error
+	UpdateClaimMapping(ctx context.Context, id string, mapping models.ClaimMapping) error
+}
+
+type AccessTokenRepositoryPorts interface {
+	Add(ctx context.Context, token *models.AccessToken) error
+	GetByID(ctx context.Context, id string) (*models.AccessToken, error)
+	GetByUserID(ctx context.Context, userID int) ([]models.AccessToken, error)
+	Touch(ctx context.Context, id string, lastUsedAt time.Time, ip string) error
+	Revoke(ctx context.Context, id string, userID int, revokedAt time.Time) error
+	RevokeByUserID(ctx context.Context, userID int, revokedAt time.Time) error
+}
+
+type ServiceAccountRepositoryPorts interface {
+	Add(ctx context.Context, account *models.ServiceAccount) error
+	GetByID(ctx context.Context, id string) (*models.ServiceAccount, error)
+	List(ctx context.Context) ([]models.ServiceAccount, error)
+	UpdateRoles(ctx context.Context, id string, roles []string, updatedBy int) error
+	UpdateStatus(ctx context.Context, id string, status constants.Status, updatedBy int) error
+	AddKey(ctx context.Context, key *models.ServiceAccountKey) error
+	GetKey(ctx context.Context, accountID, keyID string) (*models.ServiceAccountKey, error)
+	GetKeys(ctx context.Context, accountID string) ([]models.ServiceAccountKey, error)
+	TouchKey(ctx context.Context, keyID string, lastUsedAt time.Time) error
+	RevokeKeys(ctx context.Context, accountID, keyID string, revokedAt time.Time) error
+	AddAudit(ctx context.Context, entry *models.ServiceAccountAudit) error
+	GetAudit(ctx context.Context, accountID string, limit int) ([]models.ServiceAccountAudit, error)
+}
+
+type CertificateBindingRepositoryPorts interface {
+	Add(ctx context.Context, binding *models.CertificateBinding) error
+	GetByMatch(ctx context.Context, match constants.CertificateMatch, value string) (*models.CertificateBinding, error)
+	List(ctx context.Context) ([]models.CertificateBinding, error)
+	DeleteByID(ctx context.Context, id string) error
+}
+
+type ImpersonationRepositoryPorts interface {
+	Add(ctx context.Context, impersonation *models.Impersonation) error
+	List(ctx context.Context, limit int) ([]models.Impersonation, error)
+}
+
+type TrustedDeviceRepositoryPorts interface {
+	Add(ctx context.Context, device *models.TrustedDevice) error
+	GetByID(ctx context.Context, id string) (*models.TrustedDevice, error)
+	GetByUserID(ctx context.Context, userID int) ([]models.TrustedDevice, error)
+	Touch(ctx context.Context, id string, lastUsedAt time.Time) error
+	DeleteByID(ctx context.Context, id string, userID int) error
+	DeleteByUserID(ctx context.Context, userID int) error
+}
@@ -1,7 +1,11 @@
 package egress
 
-import "io"
+import (
+	"io"
+	"net/url"
+)
 
 type HttpClientPorts interface {
 	Execute(url, method string, reqPayload io.Reader, resPayload any) error
+	ExecuteForm(url string, form url.Values, resPayload any) error
 }
@@ -0,0 +1,11 @@
+package egress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+)
+
+type OtpSenderPorts interface {
+	Send(ctx context.Context, user *models.User, code string) error
+}
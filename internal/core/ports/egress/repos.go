@@ -1,9 +1,23 @@
 package egress
 
 type Repository struct {
-	HttpClient   HttpClientPorts
-	Role         RoleRepositoryPorts
-	User         UserRepositoryPorts
-	LoginHistory LoginHistoryPorts
-	Permission   PermissionRepositoryPorts
+	Cache          CacheRepositoryPorts
+	OtpSender      OtpSenderPorts
+	HttpClient     HttpClientPorts
+	Role           RoleRepositoryPorts
+	User           UserRepositoryPorts
+	LoginHistory   LoginHistoryPorts
+	Permission     PermissionRepositoryPorts
+	Device         TrustedDeviceRepositoryPorts
+	Session        SessionRepositoryPorts
+	Client         ClientRepositoryPorts
+	AccessToken    AccessTokenRepositoryPorts
+	ServiceAccount ServiceAccountRepositoryPorts
+	Certificate    CertificateBindingRepositoryPorts
+	Impersonation  ImpersonationRepositoryPorts
+	Replay         ReplayRepositoryPorts
+	RateLimit      RateLimitRepositoryPorts
+	Abuse          AbuseRepositoryPorts
+	Challenge      ChallengePorts
+	TokenCodec     TokenCodecPorts
 }
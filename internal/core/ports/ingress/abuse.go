@@ -0,0 +1,16 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type AbuseServicePorts interface {
+	Inspect(ctx context.Context, source, account string) *models.AbuseVerdict
+	RecordFailure(ctx context.Context, source, account string) *models.AbuseVerdict
+
+	Blocked(ctx *fasthttp.RequestCtx)
+	Unblock(ctx *fasthttp.RequestCtx)
+}
@@ -0,0 +1,16 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type AccessTokenServicePorts interface {
+	Authenticate(ctx context.Context, token, clientIP string) (*models.Token, error)
+
+	List(ctx *fasthttp.RequestCtx)
+	Add(ctx *fasthttp.RequestCtx)
+	Revoke(ctx *fasthttp.RequestCtx)
+}
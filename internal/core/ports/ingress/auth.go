@@ -5,7 +5,14 @@ import "github.com/valyala/fasthttp"
 type AuthServicePorts interface {
 	Session(ctx *fasthttp.RequestCtx)
 	Signin(ctx *fasthttp.RequestCtx)
+	Challenge(ctx *fasthttp.RequestCtx)
 	Signup(ctx *fasthttp.RequestCtx)
 	Otp(ctx *fasthttp.RequestCtx)
 	Verify(ctx *fasthttp.RequestCtx)
+	ChangePassword(ctx *fasthttp.RequestCtx)
+	StepUp(ctx *fasthttp.RequestCtx)
+	Refresh(ctx *fasthttp.RequestCtx)
+	Logout(ctx *fasthttp.RequestCtx)
+	LogoutAll(ctx *fasthttp.RequestCtx)
+	ForceLogout(ctx *fasthttp.RequestCtx)
 }
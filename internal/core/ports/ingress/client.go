@@ -0,0 +1,10 @@
+package ingress
+
+import "github.com/valyala/fasthttp"
+
+type ClientServicePorts interface {
+	List(ctx *fasthttp.RequestCtx)
+	Add(ctx *fasthttp.RequestCtx)
+	Delete(ctx *fasthttp.RequestCtx)
+	UpdateClaims(ctx *fasthttp.RequestCtx)
+}
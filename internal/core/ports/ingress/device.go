@@ -0,0 +1,17 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type DeviceServicePorts interface {
+	IsTrusted(ctx context.Context, user *models.User, deviceHash, deviceToken string) bool
+	Trust(ctx context.Context, user *models.User, attempt *models.LoginHistory) (*models.TrustedDevice, string, error)
+	RevokeAll(ctx context.Context, userID int) error
+
+	List(ctx *fasthttp.RequestCtx)
+	Revoke(ctx *fasthttp.RequestCtx)
+}
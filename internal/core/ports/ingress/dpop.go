@@ -0,0 +1,10 @@
+package ingress
+
+import "github.com/valyala/fasthttp"
+
+type DpopServicePorts interface {
+	Enabled() bool
+	// VerifyProof checks the DPoP proof of the request and returns the thumbprint of its key. The
+	// access token is empty at the token endpoint, otherwise the proof must carry its hash.
+	VerifyProof(ctx *fasthttp.RequestCtx, accessToken string) (string, error)
+}
@@ -6,4 +6,12 @@ type HandlerPorts interface {
 	SetPermissionHandler(healthService PermissionServicePorts)
 	SetUserHandler(userService UserServicePorts)
 	SetAuthHandler(authService AuthServicePorts)
+	SetSecurityHandler(abuseService AbuseServicePorts)
+	SetDeviceHandler(deviceService DeviceServicePorts)
+	SetSessionHandler(sessionService SessionServicePorts)
+	SetClientHandler(clientService ClientServicePorts)
+	SetOAuthHandler(oauthService OAuthServicePorts)
+	SetAccessTokenHandler(accessTokenService AccessTokenServicePorts)
+	SetServiceAccountHandler(serviceAccountService ServiceAccountServicePorts)
+	SetCertificateHandler(mtlsService MtlsServicePorts)
 }
@@ -7,5 +7,6 @@ import (
 type MiddlewarePorts interface {
 	RequestID(next fasthttp.RequestHandler) fasthttp.RequestHandler
 	PanicRecover(next fasthttp.RequestHandler) fasthttp.RequestHandler
+	RateLimit(next fasthttp.RequestHandler) fasthttp.RequestHandler
 	Authorization(requiredPermission string) func(next fasthttp.RequestHandler) fasthttp.RequestHandler
 }
@@ -0,0 +1,20 @@
+package ingress
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type MtlsServicePorts interface {
+	Enabled() bool
+	// Certificate returns the verified client certificate of the request, nil when none was presented
+	Certificate(ctx *fasthttp.RequestCtx) *x509.Certificate
+	Authenticate(ctx context.Context, cert *x509.Certificate) (*models.CertificateBinding, error)
+
+	List(ctx *fasthttp.RequestCtx)
+	Add(ctx *fasthttp.RequestCtx)
+	Delete(ctx *fasthttp.RequestCtx)
+}
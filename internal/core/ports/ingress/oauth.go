@@ -0,0 +1,25 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+)
+
+type OAuthServicePorts interface {
+	Enabled() bool
+	SetSsoCookie(ctx *fasthttp.RequestCtx, sessionID string)
+	ClearSsoCookie(ctx *fasthttp.RequestCtx)
+	NotifyLogout(ctx context.Context, userID int, sessionID string)
+
+	Authorize(ctx *fasthttp.RequestCtx)
+	Token(ctx *fasthttp.RequestCtx)
+	Logout(ctx *fasthttp.RequestCtx)
+	DeviceAuthorization(ctx *fasthttp.RequestCtx)
+	DeviceLookup(ctx *fasthttp.RequestCtx)
+	DeviceVerify(ctx *fasthttp.RequestCtx)
+	Impersonations(ctx *fasthttp.RequestCtx)
+	Introspect(ctx *fasthttp.RequestCtx)
+	Revoke(ctx *fasthttp.RequestCtx)
+	Jwks(ctx *fasthttp.RequestCtx)
+}
@@ -8,4 +8,5 @@ type PermissionServicePorts interface {
 	Add(ctx *fasthttp.RequestCtx)
 	Update(ctx *fasthttp.RequestCtx)
 	Delete(ctx *fasthttp.RequestCtx)
+	Index(ctx *fasthttp.RequestCtx)
 }
@@ -1,11 +1,21 @@
 package ingress
 
 type Repository struct {
-	Auth       AuthServicePorts
-	Handler    HandlerPorts
-	Health     HealthServicePorts
-	Role       RoleServicePorts
-	Token      TokenServicePorts
-	User       UserServicePorts
-	Permission PermissionServicePorts
+	Abuse          AbuseServicePorts
+	AccessToken    AccessTokenServicePorts
+	Auth           AuthServicePorts
+	Client         ClientServicePorts
+	Device         DeviceServicePorts
+	Dpop           DpopServicePorts
+	Handler        HandlerPorts
+	Mtls           MtlsServicePorts
+	Health         HealthServicePorts
+	OAuth          OAuthServicePorts
+	Role           RoleServicePorts
+	Session        SessionServicePorts
+	ServiceAccount ServiceAccountServicePorts
+	Token          TokenServicePorts
+	User           UserServicePorts
+	Permission     PermissionServicePorts
+	Risk           RiskServicePorts
 }
@@ -0,0 +1,11 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+)
+
+type RiskServicePorts interface {
+	Evaluate(ctx context.Context, input *models.RiskInput) *models.RiskAssessment
+}
@@ -8,4 +8,5 @@ type RoleServicePorts interface {
 	Add(ctx *fasthttp.RequestCtx)
 	Update(ctx *fasthttp.RequestCtx)
 	Delete(ctx *fasthttp.RequestCtx)
+	Permissions(ctx *fasthttp.RequestCtx)
 }
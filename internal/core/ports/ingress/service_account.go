@@ -0,0 +1,24 @@
+package ingress
+
+import (
+	"context"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type ServiceAccountServicePorts interface {
+	Enabled() bool
+	Authenticate(ctx context.Context, assertion, clientIP string) (*models.ServiceAccount, error)
+	IssueToken(ctx context.Context, account *models.ServiceAccount, clientIP string, cnf *models.Confirmation) (string, time.Duration, error)
+
+	List(ctx *fasthttp.RequestCtx)
+	Info(ctx *fasthttp.RequestCtx)
+	Add(ctx *fasthttp.RequestCtx)
+	UpdateRoles(ctx *fasthttp.RequestCtx)
+	Disable(ctx *fasthttp.RequestCtx)
+	AddKey(ctx *fasthttp.RequestCtx)
+	RevokeKey(ctx *fasthttp.RequestCtx)
+	Audit(ctx *fasthttp.RequestCtx)
+}
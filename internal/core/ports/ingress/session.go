@@ -0,0 +1,24 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
+)
+
+type SessionServicePorts interface {
+	Enabled() bool
+	Create(ctx context.Context, user *models.User, attempt *models.LoginHistory, amr []string) (*models.Session, string, error)
+	StepUp(ctx context.Context, sessionID string, userID int, amr []string) (*models.Session, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.Session, string, error)
+	EnforceLimit(ctx context.Context, user *models.User, session *models.Session) error
+	Get(ctx context.Context, id string) (*models.Session, error)
+	Validate(ctx context.Context, tokenInfo *models.Token) (*models.Session, error)
+	End(ctx context.Context, tokenInfo *models.Token) error
+	EndAll(ctx context.Context, userID int) error
+
+	List(ctx *fasthttp.RequestCtx)
+	Revoke(ctx *fasthttp.RequestCtx)
+	RevokeOthers(ctx *fasthttp.RequestCtx)
+}
@@ -1,12 +1,20 @@
 package ingress
 
 import (
+	"context"
+
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
 )
 
 type TokenServicePorts interface {
-	GenerateToken(roleID constants.Roles, permissions []string, userInfo *models.User) (string, error)
+	GenerateToken(roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (string, error)
+	IssueToken(ctx context.Context, roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (string, error)
+	ResolveToken(ctx context.Context, token string) (*models.Token, error)
+	RevokeToken(ctx context.Context, token string) error
+	PermissionIndex(ctx context.Context, version string) (*models.PermissionIndex, error)
+	GenerateLogoutToken(clientID string, userID int, sessionID string) (string, error)
+	LogoutKeys() (*models.JwkSet, error)
 	GetTokenInfo(token string) (*models.Token, error)
 	HavePermission(token, permission string) bool
 }
@@ -11,6 +11,7 @@ type Response interface {
 	SetMessage(msg string) Response
 	SetPayload(payload any) Response
 	SetToken(token string) Response
+	SetRefreshToken(token string) Response
 	SetPermission(permissions []string) Response
 	SetErrorCode(code string) Response
 	SetErrorMessage(msg string) Response
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type abuseService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewAbuseService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.AbuseServicePorts {
+	return &abuseService{
+		errCodePrefix:    "AB-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (s *abuseService) enabled() bool {
+	return s.config.Abuse != nil && s.config.Abuse.Enabled
+}
+
+// Inspect returns the current verdict for a signin attempt without recording anything. Only a
+// stored block blocks, RecordFailure creates it. Cache errors fail open so an outage does not
+// lock everyone out.
+func (s *abuseService) Inspect(ctx context.Context, source, account string) *models.AbuseVerdict {
+	verdict := &models.AbuseVerdict{Action: constants.AbuseActionNone}
+	if !s.enabled() {
+		return verdict
+	}
+
+	blocked, err := s.egressRepository.Abuse.GetBlock(ctx, source)
+	if err == nil {
+		verdict.Escalate(constants.AbuseActionBlock, blocked.Reason)
+		verdict.BlockedUntil = blocked.ExpiresAt
+		return verdict
+	}
+
+	if !errors.Is(err, utils.ErrDocumentNotFound) {
+		s.logger.Error("Failed to fetch blocked source", zap.String("source", source), zap.Error(err))
+	}
+
+	sourceAccounts, accountSources, err := s.egressRepository.Abuse.Counts(ctx, source, account, s.config.Abuse.Window)
+	if err != nil {
+		s.logger.Error("Failed to count signin failures", zap.String("source", source), zap.Error(err))
+		return verdict
+	}
+
+	s.evaluate(verdict, sourceAccounts, accountSources)
+	return verdict
+}
+
+// RecordFailure adds a failed signin to the rolling windows and escalates when thresholds are crossed
+func (s *abuseService) RecordFailure(ctx context.Context, source, account string) *models.AbuseVerdict {
+	verdict := &models.AbuseVerdict{Action: constants.AbuseActionNone}
+	if !s.enabled() {
+		return verdict
+	}
+
+	cfg := s.config.Abuse
+	sourceAccounts, accountSources, err := s.egressRepository.Abuse.RecordFailure(ctx, source, account, cfg.Window)
+	if err != nil {
+		s.logger.Error("Failed to record signin failure", zap.String("source", source), zap.Error(err))
+		return verdict
+	}
+
+	s.evaluate(verdict, sourceAccounts, accountSources)
+	if reached(cfg.Source.Block, sourceAccounts) {
+		verdict.Escalate(constants.AbuseActionBlock, "source blocked for password spraying")
+	}
+
+	if reached(cfg.Source.Alert, sourceAccounts) {
+		s.alert(ctx, "spraying:"+source, &models.AlertEvent{
+			Type:   constants.AlertPasswordSpraying,
+			Source: source,
+			Count:  sourceAccounts,
+		})
+	}
+
+	if reached(cfg.Account.Alert, accountSources) {
+		s.alert(ctx, "stuffing:"+account, &models.AlertEvent{
+			Type:    constants.AlertCredentialStuffing,
+			Account: account,
+			Count:   accountSources,
+		})
+	}
+
+	if verdict.Action != constants.AbuseActionBlock {
+		return verdict
+	}
+
+	now := time.Now()
+	blocked := &models.BlockedSource{
+		Source:    source,
+		Reason:    fmt.Sprintf("signin failures against %d accounts within %s", sourceAccounts, cfg.Window),
+		BlockedAt: now,
+		ExpiresAt: now.Add(cfg.BlockDuration),
+	}
+
+	if err := s.egressRepository.Abuse.Block(ctx, blocked); err != nil {
+		s.logger.Error("Failed to block source", zap.String("source", source), zap.Error(err))
+		return verdict
+	}
+	verdict.BlockedUntil = blocked.ExpiresAt
+
+	s.alert(ctx, "blocked:"+source, &models.AlertEvent{
+		Type:   constants.AlertSourceBlocked,
+		Source: source,
+		Count:  sourceAccounts,
+	})
+
+	return verdict
+}
+
+func (s *abuseService) evaluate(verdict *models.AbuseVerdict, sourceAccounts, accountSources int) {
+	cfg := s.config.Abuse
+	verdict.SourceAccounts = sourceAccounts
+	verdict.AccountSources = accountSources
+
+	if reached(cfg.Source.Challenge, sourceAccounts) {
+		verdict.Escalate(constants.AbuseActionChallenge, "source failing against many accounts")
+	}
+	if reached(cfg.Account.Challenge, accountSources) {
+		verdict.Escalate(constants.AbuseActionChallenge, "account failing from many sources")
+	}
+}
+
+// alert logs and publishes the event once per window for the given key
+func (s *abuseService) alert(ctx context.Context, key string, event *models.AlertEvent) {
+	cfg := s.config.Abuse
+
+	first, err := s.egressRepository.Abuse.MarkAlerted(ctx, key, cfg.Window)
+	if err != nil {
+		s.logger.Error("Failed to de-duplicate alert", zap.String("key", key), zap.Error(err))
+	}
+	if err == nil && !first {
+		return
+	}
+
+	event.Window = cfg.Window
+	event.CreatedAt = time.Now()
+
+	s.logger.Warn("Signin abuse detected",
+		zap.String("type", event.Type.String()),
+		zap.String("source", event.Source),
+		zap.String("account", event.Account),
+		zap.Int("count", event.Count),
+	)
+
+	if err := s.egressRepository.Abuse.PublishAlert(ctx, cfg.AlertChannel, event); err != nil {
+		s.logger.Error("Failed to publish alert event", zap.Error(err))
+	}
+}
+
+func reached(threshold, count int) bool {
+	return threshold > 0 && count >= threshold
+}
+
+func (s *abuseService) Blocked(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	if !s.enabled() {
+		response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Abuse detection is disabled").Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	blocked, err := s.egressRepository.Abuse.ListBlocked(ctxVal)
+	if err != nil {
+		logger.Error("Failed to fetch blocked sources", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "BL", 1),
+			Message: "Failed to fetch blocked sources",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	msg := "Blocked sources fetched successfully"
+	if len(blocked) == 0 {
+		msg = "No blocked sources found"
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).SetPayload(blocked).Send(ctx)
+}
+
+func (s *abuseService) Unblock(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		source   = utils.GetPathParam(ctx, "source")
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	if !s.enabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UB", 1),
+			Message: "Abuse detection is disabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Abuse.Unblock(ctxVal, source); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Source '%s' is not blocked", source)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "UB", 2),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to unblock source", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UB", 3),
+			Message: "Failed to unblock source",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	logger.Info("Source unblocked", zap.String("source", source))
+	response.SetStatus(true).SetStatusCode(http.StatusOK).
+		SetMessage(fmt.Sprintf("Source '%s' unblocked successfully", source)).Send(ctx)
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type accessTokenService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewAccessTokenService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.AccessTokenServicePorts {
+	return &accessTokenService{
+		errCodePrefix:    "PT-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (s *accessTokenService) enabled() bool {
+	return s.config.AccessToken != nil && s.config.AccessToken.Enabled && s.egressRepository.AccessToken != nil
+}
+
+// Authenticate resolves a personal access token into the claims the middleware works with. The
+// token never grants more than its owner currently has, so a role downgrade applies immediately.
+func (s *accessTokenService) Authenticate(ctx context.Context, token, clientIP string) (*models.Token, error) {
+	if !s.enabled() {
+		return nil, utils.ErrInvalidAccessToken
+	}
+
+	id, secret, found := strings.Cut(strings.TrimPrefix(token, constants.AccessTokenPrefix), ".")
+	if !found || id == "" || secret == "" {
+		return nil, utils.ErrInvalidAccessToken
+	}
+
+	accessToken, err := s.egressRepository.AccessToken.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(accessToken.TokenHash), []byte(utils.HashToken(id, secret))) != 1 ||
+		accessToken.RevokedAt != nil || now.After(accessToken.ExpiresAt) {
+		return nil, utils.ErrInvalidAccessToken
+	}
+
+	if len(accessToken.AllowedCIDRs) > 0 && !utils.IPInCIDRs(clientIP, accessToken.AllowedCIDRs) {
+		s.logger.Info("access token used from a disallowed address", zap.String("tokenID", id), zap.String("ip", clientIP))
+		return nil, utils.ErrInvalidAccessToken
+	}
+
+	user, err := s.egressRepository.User.GetByID(ctx, accessToken.UserID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+
+	// The owner may have been deleted since the token was created
+	if user == nil || user.Status != constants.StatusActive {
+		return nil, utils.ErrInvalidAccessToken
+	}
+
+	userGranted, err := userPermissions(ctx, s.egressRepository.Role, user)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := sliceStringToMapStruct(userGranted)
+	permissions := make(map[string]struct{}, len(accessToken.Permissions))
+	for _, permission := range accessToken.Permissions {
+		if _, found := granted[permission]; found {
+			permissions[permission] = struct{}{}
+		}
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) >= s.config.AccessToken.TouchInterval {
+		go func(id string) {
+			if err := s.egressRepository.AccessToken.Touch(context.Background(), id, now, clientIP); err != nil {
+				s.logger.Error("Failed to update access token usage", zap.String("tokenID", id), zap.Error(err))
+			}
+		}(accessToken.ID)
+	}
+
+	return &models.Token{
+		UserID:        user.ID,
+		Role:          user.Role,
+		Permissions:   permissions,
+		AccessTokenID: accessToken.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        accessToken.ID,
+			Subject:   s.config.Jwt.Subject,
+			ExpiresAt: jwt.NewNumericDate(accessToken.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(accessToken.CreatedAt),
+		},
+	}, nil
+}
+
+func (s *accessTokenService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	tokens, err := s.egressRepository.AccessToken.GetByUserID(ctxVal, tokenInfo.UserID)
+	if err != nil {
+		logger.Error("Failed to fetch access tokens", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 2),
+			Message: "Failed to fetch access tokens",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Access tokens fetched successfully").SetPayload(tokens).Send(ctx)
+}
+
+// Add creates a personal access token. The token is only returned here, only its hash is kept.
+func (s *accessTokenService) Add(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+		payload   models.AccessTokenRequest
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	// A leaked token must not be able to mint more tokens
+	if tokenInfo.AccessTokenID != "" {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 2),
+			Message: "Access tokens cannot create access tokens",
+		}).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	// An access token would outlive the delegation and drop its act claim from the audit trail
+	if tokenInfo.Act != nil {
+		logger.Warn("delegated token attempted to create an access token", zap.Int("userID", tokenInfo.UserID), zap.String("actor", tokenInfo.Act.Subject))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 11),
+			Message: "Delegated tokens cannot create access tokens",
+		}).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	if !s.enabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 3),
+			Message: "Access tokens are disabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode access token request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 4),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(s.config.AccessToken.MaxLifeSpan); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 5),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	for _, permission := range payload.Permissions {
+		if !tokenInfo.HasPermission(permission) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "AD", 6),
+				Message: fmt.Sprintf("Permission '%s' is not granted to you", permission),
+			}).SetStatusCode(http.StatusForbidden).Send(ctx)
+			return
+		}
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if max := s.config.AccessToken.MaxPerUser; max > 0 {
+		tokens, err := s.egressRepository.AccessToken.GetByUserID(ctxVal, tokenInfo.UserID)
+		if err != nil {
+			logger.Error("Failed to fetch access tokens", zap.Error(err))
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "AD", 7),
+				Message: "Failed to create access token",
+			}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+			return
+		}
+
+		active := 0
+		for _, token := range tokens {
+			if token.RevokedAt == nil && time.Now().Before(token.ExpiresAt) {
+				active++
+			}
+		}
+
+		if active >= max {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "AD", 8),
+				Message: fmt.Sprintf("You already have %d active access tokens. Revoke one first", active),
+			}).SetStatusCode(http.StatusConflict).Send(ctx)
+			return
+		}
+	}
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		logger.Error("Failed to generate access token", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 9),
+			Message: "Failed to create access token",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	accessToken := &models.AccessToken{
+		ID:           uuid.NewString(),
+		UserID:       tokenInfo.UserID,
+		Name:         payload.Name,
+		Permissions:  payload.Permissions,
+		AllowedCIDRs: payload.AllowedCIDRs,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    payload.ExpiresAt,
+	}
+	accessToken.TokenHash = utils.HashToken(accessToken.ID, secret)
+
+	if err := s.egressRepository.AccessToken.Add(ctxVal, accessToken); err != nil {
+		logger.Error("Failed to create access token", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 10),
+			Message: "Failed to create access token",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Access token created. Copy it now, it will not be shown again").
+		SetPayload(&models.AccessTokenCreated{
+			AccessToken: accessToken,
+			Token:       constants.AccessTokenPrefix + accessToken.ID + "." + secret,
+		}).Send(ctx)
+}
+
+func (s *accessTokenService) Revoke(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		tokenID   = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RV", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.AccessToken.Revoke(ctxVal, tokenID, tokenInfo.UserID, time.Now()); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Access token '%s' not found", tokenID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "RV", 2),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to revoke access token", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RV", 3),
+			Message: "Failed to revoke access token",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Access token '%s' revoked successfully", tokenID)).Send(ctx)
+}
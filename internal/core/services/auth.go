@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
@@ -89,7 +91,18 @@ func (a *authService) Session(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	token, err := a.ingressRepository.Token.GenerateToken(role.ID, role.Permissions, nil)
+	permissions, err := inheritedPermissions(ctxVal, a.egressRepository.Role, role)
+	if err != nil {
+		logger.Error("Failed to resolve session role permissions", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SN", 5),
+			Message: "Unable to process request. Please try again later.",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	token, err := a.ingressRepository.Token.GenerateToken(role.ID, permissions, nil, nil)
 	if err != nil {
 		logger.Error("Token generation failed", zap.Error(err))
 		response.SetError(&models.Error{
@@ -102,7 +115,7 @@ func (a *authService) Session(ctx *fasthttp.RequestCtx) {
 
 	// Success
 	response.SetStatus(true).SetStatusCode(http.StatusOK).SetToken(token).
-		SetPermission(role.Permissions).Send(ctx)
+		SetPermission(permissions).Send(ctx)
 }
 
 func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
@@ -136,11 +149,40 @@ func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
 	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
 	defer cancel()
 
+	clientIP := utils.ClientIP(ctx, a.config.App.Server.TrustProxy, a.config.App.Server.TrustedProxies)
+	verdict := a.ingressRepository.Abuse.Inspect(ctxVal, clientIP, loginPayload.Email)
+	switch verdict.Action {
+	case constants.AbuseActionBlock:
+		logger.Warn("signin from blocked source", zap.String("ip", clientIP), zap.Strings("reasons", verdict.Reasons))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 9),
+			Message: fmt.Sprintf("Too many failed attempts from your network. Try again at %s", verdict.BlockedUntil.Format(time.RFC1123)),
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+
+	case constants.AbuseActionChallenge:
+		logger.Warn("suspicious signin activity", zap.String("ip", clientIP), zap.Strings("reasons", verdict.Reasons))
+	}
+
+	// Challenge must be solved before the account is looked up so its existence is not revealed
+	challengeSolved := false
+	if (verdict.Action == constants.AbuseActionChallenge || a.ipChallengeRequired(ctxVal, clientIP)) && a.challengeEnabled() {
+		if !a.verifyChallenge(ctx, ctxVal, response, logger, loginPayload.ChallengeToken, clientIP) {
+			return
+		}
+		challengeSolved = true
+	}
+
 	user, err := a.egressRepository.User.GetByEmail(ctxVal, loginPayload.Email)
 	if err != nil {
 		if errors.Is(err, utils.ErrDocumentNotFound) {
 			logger.Warn("invalid credentials", zap.String("email", loginPayload.Email))
 
+			go a.ingressRepository.Abuse.RecordFailure(context.Background(), clientIP, loginPayload.Email)
+			go a.recordIPFailure(clientIP)
+
 			response.SetError(&models.Error{
 				Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 3),
 				Message: "invalid credentials",
@@ -176,6 +218,14 @@ func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
 		return
 	}
 	fail := a.countRecentFailures(ctx, user.ID)
+	if !challengeSolved && a.challengeEnabled() && fail >= a.config.Challenge.AfterFailures {
+		if !a.verifyChallenge(ctx, ctxVal, response, logger, loginPayload.ChallengeToken, clientIP) {
+			return
+		}
+	}
+
+	attempt := a.newLoginAttempt(ctx, user.ID, clientIP, loginPayload.DeviceHash)
+
 	if err := isValidPassword(user.Password, loginPayload.Password); err != nil {
 		fail++
 
@@ -184,6 +234,10 @@ func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
 			a.handleFailCounts(context.Background(), user, fail)
 		}(user, fail)
 
+		go a.ingressRepository.Abuse.RecordFailure(context.Background(), clientIP, loginPayload.Email)
+		go a.recordIPFailure(clientIP)
+		a.addLoginHistory(attempt, constants.StatusFail, "invalid credentials")
+
 		response.SetError(&models.Error{
 			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 7),
 			Message: "invalid credentials",
@@ -192,8 +246,53 @@ func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	risk := a.ingressRepository.Risk.Evaluate(ctxVal, &models.RiskInput{
+		User:       user,
+		IP:         attempt.IP,
+		UserAgent:  attempt.UserAgent,
+		DeviceHash: attempt.DeviceHash,
+		Country:    attempt.Country,
+		Asn:        attempt.Asn,
+		At:         attempt.LoginAt,
+	})
+	attempt.RiskScore = risk.Score
+	attempt.RiskReasons = risk.Reasons
+
+	switch risk.Decision {
+	case constants.RiskBlock:
+		logger.Warn("signin blocked by risk engine", zap.Int("userID", user.ID), zap.Int("score", risk.Score), zap.Strings("reasons", risk.Reasons))
+		a.addLoginHistory(attempt, constants.StatusBlocked, "blocked by risk engine")
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 12),
+			Message: "Sign in blocked for security reasons",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+
+	case constants.RiskStepUp:
+		logger.Info("signin requires step-up", zap.Int("userID", user.ID), zap.Int("score", risk.Score), zap.Strings("reasons", risk.Reasons))
+	}
+
+	if user.MfaEnabled || risk.Decision == constants.RiskStepUp {
+		deviceToken := loginPayload.DeviceToken
+		if deviceToken == "" && a.config.Device != nil {
+			deviceToken = string(ctx.Request.Header.Cookie(a.config.Device.CookieName))
+		}
+
+		if !a.ingressRepository.Device.IsTrusted(ctxVal, user, attempt.DeviceHash, deviceToken) {
+			a.startMfa(ctx, ctxVal, response, logger, user, attempt)
+			return
+		}
+		logger.Info("second factor skipped on trusted device", zap.Int("userID", user.ID))
+	}
+
 	// create a token
-	token, err := a.ingressRepository.Token.GenerateToken(user.Role, user.Permissions, user)
+	token, refreshToken, err := a.issueSigninToken(ctxVal, user, attempt, []string{constants.AmrPassword})
+	if errors.Is(err, utils.ErrSessionLimit) {
+		a.sessionLimitReached(ctx, response, logger, user, fmt.Sprintf(a.errCodePrefix, "SIN", 15))
+		return
+	}
 	if err != nil {
 		response.SetError(&models.Error{
 			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 8),
@@ -208,16 +307,42 @@ func (a *authService) Signin(ctx *fasthttp.RequestCtx) {
 		a.handleFailCounts(context.Background(), user, fail)
 	}(user, fail)
 
-	go func(user *models.User) {
-		a.egressRepository.LoginHistory.Add(context.Background(), &models.LoginHistory{
-			UserID:     user.ID,
-			Status:     constants.StatusSuccess,
-			Token:      token,
-			Permission: user.Permissions,
-		})
-	}(user)
+	a.setSessionCookies(ctx, logger, token, refreshToken)
+
+	user.Password = ""
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("success").SetPayload(user).SetToken(token).SetRefreshToken(refreshToken).SetPermission(attempt.Permission).Send(ctx)
+}
+
+// Challenge issues a challenge ahead of signin, clients may also wait for signin to demand one
+func (a *authService) Challenge(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	if !a.challengeEnabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CH", 1),
+			Message: "Challenge is disabled",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	challenge, err := a.egressRepository.Challenge.Issue(ctxVal)
+	if err != nil {
+		logger.Error("Failed to issue challenge", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CH", 2),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
 
-	response.SetStatus(true).SetMessage("success").SetPayload(user).SetToken(token).SetPermission(user.Permissions)
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Challenge issued").SetPayload(challenge).Send(ctx)
 }
 
 func (a *authService) Signup(ctx *fasthttp.RequestCtx) {
@@ -227,38 +352,1004 @@ func (a *authService) Signup(ctx *fasthttp.RequestCtx) {
 	// response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
 }
 
+// Otp re-sends the code for a pending second factor
 func (a *authService) Otp(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	var otpPayload = models.OtpRequest{}
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&otpPayload); err != nil {
+		logger.Warn("Failed to decode otp request", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	otpPayload.Sanitize()
+	if err := otpPayload.Validate(); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	var challenge models.MfaChallenge
+	if _, err := a.egressRepository.Cache.Get(ctxVal, fmt.Sprintf(constants.CacheKeyMfa, otpPayload.MfaToken), &challenge); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 3),
+			Message: "Verification expired. Please sign in again",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	wait := time.Duration(a.config.App.Login.Otp.WaitSecondsBeforeOtpRetry) * time.Second
+	if retryAt := challenge.LastSentAt.Add(wait); time.Now().Before(retryAt) {
+		ctx.Response.Header.Set(constants.RetryAfter.String(), strconv.Itoa(int(time.Until(retryAt).Seconds())+1))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 4),
+			Message: fmt.Sprintf("Please wait %d seconds before requesting a new code", a.config.App.Login.Otp.WaitSecondsBeforeOtpRetry),
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusTooManyRequests).Send(ctx)
+		return
+	}
 
+	user, err := a.egressRepository.User.GetByID(ctxVal, challenge.UserID)
+	if err != nil || user == nil {
+		logger.Error("Failed to fetch user for otp", zap.Int("userID", challenge.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 5),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if err := a.sendOtp(ctxVal, user, &challenge); err != nil {
+		logger.Error("Failed to send otp", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "OTP", 6),
+			Message: "Failed to send verification code",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Verification code sent").Send(ctx)
 }
 
+// Verify completes a signin that was stepped up to a second factor
 func (a *authService) Verify(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
 
-}
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	var verifyPayload = models.VerifyRequest{}
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&verifyPayload); err != nil {
+		logger.Warn("Failed to decode verify request", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
 
-// `hashedPassword` is fetched from DB (as []byte or string)
-// `inputPassword` is the plain password the user typed
-func isValidPassword(hashedPassword, inputPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(inputPassword))
-}
+	verifyPayload.Sanitize()
+	if err := verifyPayload.Validate(); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
 
-func (a *authService) handleFailCounts(ctx context.Context, user *models.User, failCount int) {
-	if failCount >= a.config.App.Login.MaxFailedAttempts {
-		a.egressRepository.User.LockByID(ctx, user.ID, time.Now().Add(a.config.App.Login.LockoutDurationMinutes))
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	key := fmt.Sprintf(constants.CacheKeyMfa, verifyPayload.MfaToken)
+	var challenge models.MfaChallenge
+	if _, err := a.egressRepository.Cache.Get(ctxVal, key, &challenge); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 3),
+			Message: "Verification expired. Please sign in again",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	// The attempt is counted before the code is compared, parallel guesses each take one
+	attemptsKey := fmt.Sprintf(constants.CacheKeyMfaAttempts, verifyPayload.MfaToken)
+	attempts, err := a.egressRepository.Cache.Incr(ctxVal, attemptsKey, time.Until(challenge.ExpiresAt))
+	if err != nil {
+		logger.Error("Failed to count verification attempts", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 11),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if attempts > a.config.App.Login.Otp.MaxAttempts || subtle.ConstantTimeCompare([]byte(challenge.CodeHash), []byte(utils.HashToken(challenge.Token, verifyPayload.Code))) != 1 {
+		if attempts >= a.config.App.Login.Otp.MaxAttempts {
+			_ = a.egressRepository.Cache.Delete(ctxVal, key)
+		}
+
+		logger.Warn("invalid verification code", zap.Int("userID", challenge.UserID), zap.Int("attempts", attempts))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 4),
+			Message: "invalid verification code",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	// Single use, only the request that consumes the challenge completes the signin
+	if err := a.egressRepository.Cache.GetDel(ctxVal, key, nil); err != nil {
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			logger.Error("Failed to consume verification", zap.Error(err))
+		}
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 3),
+			Message: "Verification expired. Please sign in again",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+	_ = a.egressRepository.Cache.Delete(ctxVal, attemptsKey)
+
+	user, err := a.egressRepository.User.GetByID(ctxVal, challenge.UserID)
+	if err != nil || user == nil {
+		logger.Error("Failed to fetch user for verification", zap.Int("userID", challenge.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 5),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if user.Status != constants.StatusActive {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 6),
+			Message: fmt.Sprintf("your account is %s", user.Status),
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	if challenge.StepUp {
+		a.completeStepUp(ctx, ctxVal, response, logger, user, &challenge)
+		return
+	}
+
+	attempt := &models.LoginHistory{
+		UserID:      user.ID,
+		IP:          challenge.IP,
+		UserAgent:   challenge.UserAgent,
+		DeviceHash:  challenge.DeviceHash,
+		Country:     challenge.Country,
+		Asn:         challenge.Asn,
+		RiskScore:   challenge.RiskScore,
+		RiskReasons: challenge.RiskReasons,
+		LoginAt:     time.Now(),
+	}
+
+	token, refreshToken, err := a.issueSigninToken(ctxVal, user, attempt, []string{constants.AmrPassword, constants.AmrOtp})
+	if errors.Is(err, utils.ErrSessionLimit) {
+		a.sessionLimitReached(ctx, response, logger, user, fmt.Sprintf(a.errCodePrefix, "VR", 8))
+		return
+	}
+	if err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 7),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	user.Password = ""
+	payload := &models.VerifyResponse{User: user}
+
+	// Remembering the device is best effort, the signin already succeeded
+	if verifyPayload.RememberDevice && attempt.DeviceHash != "" && a.config.Device != nil && a.config.Device.Enabled {
+		device, deviceToken, err := a.ingressRepository.Device.Trust(ctxVal, user, attempt)
+		if err != nil {
+			logger.Error("Failed to trust device", zap.Int("userID", user.ID), zap.Error(err))
+		} else {
+			cnf := a.config.Device
+			setCookie(ctx, cnf.CookieName, deviceToken, cnf.CookieDomain, cnf.CookieSecure, true, fasthttp.CookieSameSiteLaxMode, device.ExpiresAt)
+
+			payload.DeviceToken = deviceToken
+			payload.TrustedUntil = device.ExpiresAt
+		}
 	}
+
+	a.setSessionCookies(ctx, logger, token, refreshToken)
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("success").SetPayload(payload).SetToken(token).SetRefreshToken(refreshToken).SetPermission(attempt.Permission).Send(ctx)
 }
 
-func (a *authService) countRecentFailures(ctx context.Context, userID int) int {
-	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+// ChangePassword replaces the password of the signed in user and revokes their trusted devices
+func (a *authService) ChangePassword(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	tokenInfo := getTokenInfo(ctx)
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 1),
+			Message: "Sign in required",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	var passwordPayload = models.ChangePasswordRequest{}
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&passwordPayload); err != nil {
+		logger.Warn("Failed to decode change password request", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 2),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	passwordPayload.Sanitize()
+	if err := passwordPayload.Validate(); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 3),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
 	defer cancel()
 
-	before := time.Now().Add(-a.config.App.Login.LockoutDurationMinutes)
-	history, _ := a.egressRepository.LoginHistory.GetByIDAndLoginAt(ctx, userID, before)
-	fail := 0
-	for _, login := range history {
-		if login.Status == constants.StatusSuccess {
-			break
-		}
-		fail++
+	user, err := a.egressRepository.User.GetByID(ctxVal, tokenInfo.UserID)
+	if err != nil || user == nil {
+		logger.Error("Failed to fetch user", zap.Int("userID", tokenInfo.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 4),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
 	}
-	return fail
+
+	if err := isValidPassword(user.Password, passwordPayload.CurrentPassword); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 5),
+			Message: "invalid credentials",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(passwordPayload.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("Failed to hash password", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 6),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if err := a.egressRepository.User.UpdatePassword(ctxVal, user.ID, string(hashed), time.Now()); err != nil {
+		logger.Error("Failed to update password", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "CP", 7),
+			Message: "Failed to update password",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	// Devices trusted before the change are rejected by PasswordChangedAt even if this fails
+	if err := a.ingressRepository.Device.RevokeAll(ctxVal, user.ID); err != nil {
+		logger.Error("Failed to revoke trusted devices", zap.Int("userID", user.ID), zap.Error(err))
+	}
+
+	if a.config.Device != nil {
+		setCookie(ctx, a.config.Device.CookieName, "", a.config.Device.CookieDomain, a.config.Device.CookieSecure, true, fasthttp.CookieSameSiteLaxMode, time.Unix(0, 0))
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Password changed successfully").Send(ctx)
+}
+
+// StepUp sends a second factor to the signed in user, verifying it raises the authentication of
+// the current session without signing in again
+func (a *authService) StepUp(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	tokenInfo := getTokenInfo(ctx)
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SU", 1),
+			Message: "Sign in required",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	user, err := a.egressRepository.User.GetByID(ctxVal, tokenInfo.UserID)
+	if err != nil || user == nil {
+		logger.Error("Failed to fetch user for step-up", zap.Int("userID", tokenInfo.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SU", 2),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if user.Status != constants.StatusActive {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SU", 3),
+			Message: fmt.Sprintf("your account is %s", user.Status),
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	attempt := a.newLoginAttempt(ctx, user.ID, utils.ClientIP(ctx, a.config.App.Server.TrustProxy, a.config.App.Server.TrustedProxies), "")
+	challenge, err := a.newMfaChallenge(user, attempt)
+	if err == nil {
+		challenge.StepUp = true
+		challenge.SessionID = tokenInfo.SessionID
+		err = a.sendOtp(ctxVal, user, challenge)
+	}
+	if err != nil {
+		logger.Error("Failed to start step-up", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SU", 4),
+			Message: "Failed to send verification code",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Verification code sent").SetPayload(&models.MfaRequired{
+		MfaToken:  challenge.Token,
+		Methods:   []string{constants.AmrOtp},
+		ExpiresAt: challenge.ExpiresAt,
+	}).Send(ctx)
+}
+
+// Refresh exchanges a refresh token for a new access token and rotates the refresh token
+func (a *authService) Refresh(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	if !a.ingressRepository.Session.Enabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 1),
+			Message: "Refresh tokens are disabled",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	var refreshPayload = models.RefreshRequest{}
+	if len(ctx.PostBody()) > 0 {
+		if err := json.Unmarshal(ctx.PostBody(), &refreshPayload); err != nil {
+			logger.Warn("Failed to decode refresh request", zap.Error(err))
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(a.errCodePrefix, "RF", 2),
+				Message: "Invalid request format",
+				Detail:  err,
+			}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+			return
+		}
+	}
+
+	// Browsers in cookie mode send the refresh token as a cookie
+	if refreshPayload.RefreshToken == "" && a.cookieMode() {
+		refreshPayload.RefreshToken = string(ctx.Request.Header.Cookie(a.config.Cookie.RefreshName))
+	}
+
+	refreshPayload.Sanitize()
+	if err := refreshPayload.Validate(); err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 3),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	session, refreshToken, err := a.ingressRepository.Session.Refresh(ctxVal, refreshPayload.RefreshToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSession) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(a.errCodePrefix, "RF", 4),
+				Message: "Session expired or revoked. Please sign in again",
+				Detail:  nil,
+			}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to refresh session", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 5),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	user, err := a.egressRepository.User.GetByID(ctxVal, session.UserID)
+	if err != nil || user == nil {
+		logger.Error("Failed to fetch user for refresh", zap.Int("userID", session.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 6),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if user.Status != constants.StatusActive {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 7),
+			Message: fmt.Sprintf("your account is %s", user.Status),
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	if err := a.ingressRepository.Session.EnforceLimit(ctxVal, user, session); err != nil {
+		if errors.Is(err, utils.ErrSessionLimit) {
+			logger.Info("session signed out by concurrent session limit", zap.Int("userID", user.ID), zap.String("sessionID", session.ID))
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(a.errCodePrefix, "RF", 9),
+				Message: "Session signed out because the maximum concurrent sessions was reached. Please sign in again",
+				Detail:  nil,
+			}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to enforce session limit", zap.Int("userID", user.ID), zap.Error(err))
+	}
+
+	permissions, err := userPermissions(ctxVal, a.egressRepository.Role, user)
+	if err != nil {
+		logger.Error("Failed to resolve user permissions", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 10),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	token, err := a.ingressRepository.Token.GenerateToken(user.Role, permissions, user, &models.TokenOptions{
+		SessionID: session.ID,
+		NotAfter:  session.ExpiresAt,
+		Acr:       session.Acr,
+		Amr:       session.Amr,
+		AuthTime:  session.AuthTime,
+	})
+	if err != nil {
+		logger.Error("Token generation failed", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "RF", 8),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	a.setSessionCookies(ctx, logger, token, refreshToken)
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("success").SetToken(token).SetRefreshToken(refreshToken).SetPermission(permissions).Send(ctx)
+}
+
+// Logout signs out the session of the current token
+func (a *authService) Logout(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	tokenInfo := getTokenInfo(ctx)
+	if tokenInfo == nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "LO", 1),
+			Message: "Sign in required",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	if tokenInfo.SessionID != "" {
+		a.ingressRepository.OAuth.NotifyLogout(ctxVal, tokenInfo.UserID, tokenInfo.SessionID)
+	}
+
+	if err := a.ingressRepository.Session.End(ctxVal, tokenInfo); err != nil {
+		logger.Error("Failed to sign out", zap.Int("userID", tokenInfo.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "LO", 2),
+			Message: "Failed to sign out",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	a.clearSessionCookies(ctx)
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Signed out successfully").Send(ctx)
+}
+
+// LogoutAll signs the current user out of every session
+func (a *authService) LogoutAll(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	tokenInfo := getTokenInfo(ctx)
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "LA", 1),
+			Message: "Sign in required",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	a.ingressRepository.OAuth.NotifyLogout(ctxVal, tokenInfo.UserID, "")
+
+	if err := a.ingressRepository.Session.EndAll(ctxVal, tokenInfo.UserID); err != nil {
+		logger.Error("Failed to sign out everywhere", zap.Int("userID", tokenInfo.UserID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "LA", 2),
+			Message: "Failed to sign out",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	a.clearSessionCookies(ctx)
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Signed out of all sessions successfully").Send(ctx)
+}
+
+// ForceLogout lets an administrator sign any user out of every session
+func (a *authService) ForceLogout(ctx *fasthttp.RequestCtx) {
+	reqID := utils.GetField(ctx, constants.CtxRequestID)
+	logger := a.repository.Logger.With(zap.String("requestID", reqID))
+
+	response := response.NewResponse(reqID, a.config.App.Server.Compression, logger)
+	userID, err := strconv.Atoi(utils.GetPathParam(ctx, "id"))
+	if err != nil || userID <= 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "FL", 1),
+			Message: "Invalid user id",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Duration(1*time.Minute))
+	defer cancel()
+
+	a.ingressRepository.OAuth.NotifyLogout(ctxVal, userID, "")
+
+	if err := a.ingressRepository.Session.EndAll(ctxVal, userID); err != nil {
+		logger.Error("Failed to force sign out", zap.Int("userID", userID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "FL", 2),
+			Message: "Failed to sign out user",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if tokenInfo := getTokenInfo(ctx); tokenInfo != nil {
+		logger.Info("user signed out by administrator", zap.Int("userID", userID), zap.Int("adminID", tokenInfo.UserID))
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("User '%d' signed out of all sessions", userID)).Send(ctx)
+}
+
+// `hashedPassword` is fetched from DB (as []byte or string)
+// `inputPassword` is the plain password the user typed
+func isValidPassword(hashedPassword, inputPassword string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(inputPassword))
+}
+
+func (a *authService) handleFailCounts(ctx context.Context, user *models.User, failCount int) {
+	if failCount >= a.config.App.Login.MaxFailedAttempts {
+		a.egressRepository.User.LockByID(ctx, user.ID, time.Now().Add(a.config.App.Login.LockoutDurationMinutes))
+	}
+}
+
+func (a *authService) countRecentFailures(ctx context.Context, userID int) int {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	before := time.Now().Add(-a.config.App.Login.LockoutDurationMinutes)
+	history, _ := a.egressRepository.LoginHistory.GetByIDAndLoginAt(ctx, userID, before)
+	fail := 0
+	for _, login := range history {
+		if login.Status == constants.StatusSuccess {
+			break
+		}
+		// Pending step-up and risk blocks are not password failures
+		if login.Status == constants.StatusFail {
+			fail++
+		}
+	}
+	return fail
+}
+
+func (a *authService) challengeEnabled() bool {
+	return a.config.Challenge != nil && a.config.Challenge.Enabled && a.egressRepository.Challenge != nil
+}
+
+// ipChallengeRequired reports whether the failed signins from the IP call for a challenge, cache
+// errors fail open like the abuse verdict
+func (a *authService) ipChallengeRequired(ctx context.Context, clientIP string) bool {
+	if !a.challengeEnabled() || a.config.Challenge.IPFailures == 0 {
+		return false
+	}
+
+	var failures int
+	if _, err := a.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyIPFailures, clientIP), &failures); err != nil {
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			a.repository.Logger.Error("Failed to count signin failures of IP", zap.String("ip", clientIP), zap.Error(err))
+		}
+		return false
+	}
+	return failures >= a.config.Challenge.IPFailures
+}
+
+// recordIPFailure counts a failed signin from the IP, whether or not the account exists
+func (a *authService) recordIPFailure(clientIP string) {
+	if !a.challengeEnabled() || a.config.Challenge.IPFailures == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := a.egressRepository.Cache.Incr(ctx, fmt.Sprintf(constants.CacheKeyIPFailures, clientIP), a.config.Challenge.IPWindow); err != nil {
+		a.repository.Logger.Error("Failed to record signin failure of IP", zap.String("ip", clientIP), zap.Error(err))
+	}
+}
+
+// verifyChallenge checks the submitted challenge token. When it is missing or invalid a fresh
+// challenge is returned to the client and false is returned.
+func (a *authService) verifyChallenge(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, token, clientIP string) bool {
+	if token != "" {
+		err := a.egressRepository.Challenge.Verify(ctxVal, token, clientIP)
+		if err == nil {
+			return true
+		}
+
+		if !errors.Is(err, utils.ErrInvalidChallenge) {
+			logger.Error("Challenge verification failed", zap.Error(err))
+		}
+		logger.Warn("invalid challenge token", zap.String("ip", clientIP), zap.Error(err))
+	}
+
+	challenge, err := a.egressRepository.Challenge.Issue(ctxVal)
+	if err != nil {
+		logger.Error("Failed to issue challenge", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 11),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return false
+	}
+
+	response.SetError(&models.Error{
+		Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 10),
+		Message: "Challenge required",
+		Detail:  challenge,
+	}).SetStatus(false).SetStatusCode(http.StatusPreconditionRequired).Send(ctx)
+	return false
+}
+
+// newLoginAttempt captures the request signals recorded on the login history
+func (a *authService) newLoginAttempt(ctx *fasthttp.RequestCtx, userID int, clientIP, deviceHash string) *models.LoginHistory {
+	attempt := &models.LoginHistory{
+		UserID:     userID,
+		IP:         clientIP,
+		UserAgent:  utils.Sanitize(string(ctx.Request.Header.Peek(constants.UserAgent.String()))),
+		DeviceHash: deviceHash,
+		LoginAt:    time.Now(),
+	}
+
+	if cfg := a.config.Risk; cfg != nil {
+		if cfg.CountryHeader != "" {
+			attempt.Country = utils.SanitizeLower(string(ctx.Request.Header.Peek(cfg.CountryHeader)))
+		}
+		if cfg.AsnHeader != "" {
+			attempt.Asn = utils.Sanitize(string(ctx.Request.Header.Peek(cfg.AsnHeader)))
+		}
+	}
+
+	return attempt
+}
+
+func (a *authService) addLoginHistory(attempt *models.LoginHistory, status constants.Status, reason string) {
+	attempt.Status = status
+	attempt.Reason = reason
+
+	go func(attempt models.LoginHistory) {
+		if err := a.egressRepository.LoginHistory.Add(context.Background(), &attempt); err != nil {
+			a.repository.Logger.Error("Failed to add login history", zap.Int("userID", attempt.UserID), zap.Error(err))
+		}
+	}(*attempt)
+}
+
+// issueSigninToken returns the access token and, when sessions are enabled, the refresh token
+func (a *authService) issueSigninToken(ctx context.Context, user *models.User, attempt *models.LoginHistory, amr []string) (string, string, error) {
+	var (
+		opts = &models.TokenOptions{
+			Acr:      acrFor(amr),
+			Amr:      amr,
+			AuthTime: attempt.LoginAt,
+		}
+		refreshToken string
+	)
+
+	// Resolved first, a failure must not leave a session behind
+	permissions, err := userPermissions(ctx, a.egressRepository.Role, user)
+	if err != nil {
+		return "", "", err
+	}
+
+	if a.ingressRepository.Session.Enabled() {
+		session, refresh, err := a.ingressRepository.Session.Create(ctx, user, attempt, amr)
+		if err != nil {
+			return "", "", err
+		}
+		opts.SessionID = session.ID
+		opts.NotAfter = session.ExpiresAt
+		opts.AuthTime = session.AuthTime
+		refreshToken = refresh
+	}
+
+	token, err := a.ingressRepository.Token.GenerateToken(user.Role, permissions, user, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	attempt.Token = token
+	attempt.Permission = permissions
+	a.addLoginHistory(attempt, constants.StatusSuccess, "")
+
+	return token, refreshToken, nil
+}
+
+// completeStepUp issues a token with the raised authentication, bound to the same session
+func (a *authService) completeStepUp(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, user *models.User, challenge *models.MfaChallenge) {
+	permissions, err := userPermissions(ctxVal, a.egressRepository.Role, user)
+	if err != nil {
+		logger.Error("Failed to resolve user permissions", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 12),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	amr := []string{constants.AmrPassword, constants.AmrOtp}
+	opts := &models.TokenOptions{
+		Acr:      acrFor(amr),
+		Amr:      amr,
+		AuthTime: time.Now(),
+	}
+
+	if challenge.SessionID != "" && a.ingressRepository.Session.Enabled() {
+		session, err := a.ingressRepository.Session.StepUp(ctxVal, challenge.SessionID, user.ID, amr)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidSession) {
+				response.SetError(&models.Error{
+					Code:    fmt.Sprintf(a.errCodePrefix, "VR", 9),
+					Message: "Session expired or revoked. Please sign in again",
+					Detail:  nil,
+				}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+				return
+			}
+
+			logger.Error("Failed to step up session", zap.String("sessionID", challenge.SessionID), zap.Error(err))
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(a.errCodePrefix, "VR", 10),
+				Message: "Something went wrong! Please try after sometime",
+				Detail:  nil,
+			}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+			return
+		}
+
+		opts.SessionID = session.ID
+		opts.NotAfter = session.ExpiresAt
+		opts.AuthTime = session.AuthTime
+	}
+
+	token, err := a.ingressRepository.Token.GenerateToken(user.Role, permissions, user, opts)
+	if err != nil {
+		logger.Error("Token generation failed", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "VR", 10),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	a.setSessionCookies(ctx, logger, token, "")
+
+	user.Password = ""
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("success").SetPayload(&models.VerifyResponse{User: user}).SetToken(token).SetPermission(permissions).Send(ctx)
+}
+
+func (a *authService) cookieMode() bool {
+	return a.config.Cookie != nil && a.config.Cookie.Enabled
+}
+
+// setSessionCookies hands the tokens to browsers as HttpOnly cookies along with the readable CSRF
+// token they must echo back. An empty refresh token keeps the current refresh cookie. The SSO
+// cookie is set whenever single sign-on is enabled, so other applications can reuse the session.
+func (a *authService) setSessionCookies(ctx *fasthttp.RequestCtx, logger ports.Logger, token, refreshToken string) {
+	if !a.cookieMode() && !a.ingressRepository.OAuth.Enabled() {
+		return
+	}
+
+	tokenInfo, err := a.ingressRepository.Token.GetTokenInfo(token)
+	if err != nil {
+		logger.Error("Failed to read issued token", zap.Error(err))
+		return
+	}
+
+	a.ingressRepository.OAuth.SetSsoCookie(ctx, tokenInfo.SessionID)
+	if !a.cookieMode() {
+		return
+	}
+
+	var (
+		cnf       = a.config.Cookie
+		sameSite  = sameSiteMode(cnf.SameSite)
+		expiresAt = tokenInfo.ExpiresAt.Time
+	)
+
+	setCookie(ctx, cnf.AccessName, token, cnf.Domain, cnf.Secure, true, sameSite, expiresAt)
+	setCookie(ctx, cnf.CsrfName, utils.CsrfToken(cnf.SecretKey, tokenInfo.ID), cnf.Domain, cnf.Secure, false, sameSite, expiresAt)
+
+	if refreshToken != "" {
+		refreshExpiresAt := expiresAt
+		if a.ingressRepository.Session.Enabled() {
+			refreshExpiresAt = time.Now().Add(a.config.Session.AbsoluteLifetime)
+		}
+		setCookie(ctx, cnf.RefreshName, refreshToken, cnf.Domain, cnf.Secure, true, sameSite, refreshExpiresAt)
+	}
+}
+
+func (a *authService) clearSessionCookies(ctx *fasthttp.RequestCtx) {
+	a.ingressRepository.OAuth.ClearSsoCookie(ctx)
+	if !a.cookieMode() {
+		return
+	}
+
+	cnf := a.config.Cookie
+	for _, name := range []string{cnf.AccessName, cnf.RefreshName, cnf.CsrfName} {
+		setCookie(ctx, name, "", cnf.Domain, cnf.Secure, true, sameSiteMode(cnf.SameSite), time.Unix(0, 0))
+	}
+}
+
+// sessionLimitReached answers a signin rejected by the concurrent session limit
+func (a *authService) sessionLimitReached(ctx *fasthttp.RequestCtx, response ports.Response, logger ports.Logger, user *models.User, code string) {
+	logger.Info("signin rejected by concurrent session limit", zap.Int("userID", user.ID))
+
+	response.SetError(&models.Error{
+		Code:    code,
+		Message: "Maximum concurrent sessions reached. Sign out of another session and try again",
+		Detail:  nil,
+	}).SetStatus(false).SetStatusCode(http.StatusConflict).Send(ctx)
+}
+
+// startMfa parks the signin until the second factor is verified
+func (a *authService) startMfa(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, user *models.User, attempt *models.LoginHistory) {
+	challenge, err := a.newMfaChallenge(user, attempt)
+	if err != nil {
+		logger.Error("Failed to create mfa token", zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 14),
+			Message: "Something went wrong! Please try after sometime",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if err := a.sendOtp(ctxVal, user, challenge); err != nil {
+		logger.Error("Failed to start second factor", zap.Int("userID", user.ID), zap.Error(err))
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 14),
+			Message: "Failed to send verification code",
+			Detail:  nil,
+		}).SetStatus(false).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	a.addLoginHistory(attempt, constants.StatusPending, "second factor required")
+
+	response.SetError(&models.Error{
+		Code:    fmt.Sprintf(a.errCodePrefix, "SIN", 13),
+		Message: "Additional verification required",
+		Detail: &models.MfaRequired{
+			MfaToken:  challenge.Token,
+			Methods:   []string{constants.AmrOtp},
+			ExpiresAt: challenge.ExpiresAt,
+		},
+	}).SetStatus(false).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+}
+
+func (a *authService) newMfaChallenge(user *models.User, attempt *models.LoginHistory) (*models.MfaChallenge, error) {
+	mfaToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MfaChallenge{
+		Token:       mfaToken,
+		UserID:      user.ID,
+		ExpiresAt:   time.Now().Add(a.config.App.Login.Otp.LifeSpan),
+		IP:          attempt.IP,
+		UserAgent:   attempt.UserAgent,
+		DeviceHash:  attempt.DeviceHash,
+		Country:     attempt.Country,
+		Asn:         attempt.Asn,
+		RiskScore:   attempt.RiskScore,
+		RiskReasons: attempt.RiskReasons,
+	}, nil
+}
+
+// sendOtp generates a fresh code, stores the challenge and delivers the code
+func (a *authService) sendOtp(ctx context.Context, user *models.User, challenge *models.MfaChallenge) error {
+	code, err := utils.NumericCode(a.config.App.Login.Otp.Length)
+	if err != nil {
+		return err
+	}
+
+	challenge.CodeHash = utils.HashToken(challenge.Token, code)
+	challenge.LastSentAt = time.Now()
+
+	key := fmt.Sprintf(constants.CacheKeyMfa, challenge.Token)
+	if err := a.egressRepository.Cache.Add(ctx, key, challenge, time.Until(challenge.ExpiresAt), constants.CacheUpdate); err != nil {
+		return err
+	}
+
+	return a.egressRepository.OtpSender.Send(ctx, user, code)
 }
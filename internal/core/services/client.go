@@ -0,0 +1,252 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type clientService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewClientService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.ClientServicePorts {
+	return &clientService{
+		errCodePrefix:    "CL-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (s *clientService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	clients, err := s.egressRepository.Client.List(ctxVal)
+	if err != nil {
+		logger.Error("Failed to fetch clients", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 1),
+			Message: "Failed to fetch clients",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	msg := "Clients fetched successfully"
+	if len(clients) == 0 {
+		msg = "No clients found"
+		clients = nil
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).SetPayload(clients).Send(ctx)
+}
+
+// Add registers an application. The secret of a confidential client is only returned here.
+func (s *clientService) Add(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+		payload   models.ClientRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode client request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	created := &models.ClientCreated{
+		Client: &models.Client{
+			ID:                    uuid.NewString(),
+			Name:                  payload.Name,
+			RedirectURIs:          payload.RedirectURIs,
+			BackchannelLogoutURI:  payload.BackchannelLogoutURI,
+			FrontchannelLogoutURI: payload.FrontchannelLogoutURI,
+			TokenFormat:           payload.TokenFormat,
+			ClaimMapping:          payload.ClaimMapping,
+			Status:                constants.StatusActive,
+			CreatedAt:             time.Now(),
+		},
+	}
+	if tokenInfo != nil {
+		created.CreatedBy = tokenInfo.UserID
+	}
+
+	if created.TokenFormat == "" {
+		created.TokenFormat = constants.TokenFormatJwt
+	}
+
+	if payload.Confidential {
+		secret, err := utils.RandomToken(32)
+		if err != nil {
+			logger.Error("Failed to generate client secret", zap.Error(err))
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "AD", 3),
+				Message: "Failed to create client",
+				Detail:  nil,
+			}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+			return
+		}
+		created.Secret = secret
+		created.SecretHash = utils.HashToken(created.ID, secret)
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Client.Add(ctxVal, created.Client); err != nil {
+		logger.Error("Failed to create client", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 4),
+			Message: "Failed to create client",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Client created successfully").SetPayload(created).Send(ctx)
+}
+
+func (s *clientService) Delete(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		clientID = utils.GetPathParam(ctx, "id")
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Client.DeleteByID(ctxVal, clientID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Client '%s' not found", clientID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "DE", 1),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to delete client", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 2),
+			Message: "Failed to delete client",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Client '%s' deleted successfully", clientID)).Send(ctx)
+}
+
+// UpdateClaims replaces the claim mapping of the client, it applies to tokens issued from now on
+func (s *clientService) UpdateClaims(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		clientID = utils.GetPathParam(ctx, "id")
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		payload  models.ClaimMappingRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode claim mapping request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "CM", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "CM", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Client.UpdateClaimMapping(ctxVal, clientID, payload.ClaimMapping); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Client '%s' not found", clientID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "CM", 3),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to update claim mapping", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "CM", 4),
+			Message: "Failed to update claim mapping",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Claim mapping of client '%s' updated successfully", clientID)).SetPayload(payload.ClaimMapping).Send(ctx)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type deviceService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewDeviceService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.DeviceServicePorts {
+	return &deviceService{
+		errCodePrefix:    "DV-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (d *deviceService) enabled() bool {
+	return d.config.Device != nil && d.config.Device.Enabled
+}
+
+// IsTrusted checks the signed device token against the stored device.
+// Devices trusted before the last password change are no longer trusted.
+func (d *deviceService) IsTrusted(ctx context.Context, user *models.User, deviceHash, deviceToken string) bool {
+	if !d.enabled() || deviceHash == "" || deviceToken == "" {
+		return false
+	}
+
+	parts := strings.Split(deviceToken, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	deviceID, expStr, signature := parts[0], parts[1], parts[2]
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() >= exp {
+		return false
+	}
+
+	expected := d.sign(deviceID, user.ID, deviceHash, exp)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return false
+	}
+
+	device, err := d.egressRepository.Device.GetByID(ctx, deviceID)
+	if err != nil {
+		if !errors.Is(err, utils.ErrDocumentNotFound) {
+			d.logger.Error("Failed to fetch trusted device", zap.String("deviceID", deviceID), zap.Error(err))
+		}
+		return false
+	}
+
+	if device.UserID != user.ID || device.DeviceHash != deviceHash || time.Now().After(device.ExpiresAt) {
+		return false
+	}
+
+	if !user.PasswordChangedAt.IsZero() && device.CreatedAt.Before(user.PasswordChangedAt) {
+		return false
+	}
+
+	if err := d.egressRepository.Device.Touch(ctx, device.ID, time.Now()); err != nil {
+		d.logger.Error("Failed to update trusted device", zap.String("deviceID", device.ID), zap.Error(err))
+	}
+
+	return true
+}
+
+// Trust remembers the device of the attempt and returns its signed token
+func (d *deviceService) Trust(ctx context.Context, user *models.User, attempt *models.LoginHistory) (*models.TrustedDevice, string, error) {
+	if !d.enabled() {
+		return nil, "", errors.New("trusted devices are disabled")
+	}
+
+	if attempt.DeviceHash == "" {
+		return nil, "", errors.New("device hash is required to trust a device")
+	}
+
+	now := time.Now()
+	device := &models.TrustedDevice{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		DeviceHash: attempt.DeviceHash,
+		UserAgent:  attempt.UserAgent,
+		IP:         attempt.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(d.config.Device.LifeSpan),
+	}
+
+	if err := d.egressRepository.Device.Add(ctx, device); err != nil {
+		return nil, "", fmt.Errorf("failed to add trusted device: %w", err)
+	}
+
+	exp := device.ExpiresAt.Unix()
+	token := fmt.Sprintf("%s.%d.%s", device.ID, exp, d.sign(device.ID, user.ID, device.DeviceHash, exp))
+
+	return device, token, nil
+}
+
+func (d *deviceService) RevokeAll(ctx context.Context, userID int) error {
+	if d.egressRepository.Device == nil {
+		return nil
+	}
+	return d.egressRepository.Device.DeleteByUserID(ctx, userID)
+}
+
+func (d *deviceService) sign(deviceID string, userID int, deviceHash string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(d.config.Device.SecretKey))
+	fmt.Fprintf(mac, "%s|%d|%s|%d", deviceID, userID, deviceHash, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (d *deviceService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = d.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, d.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(d.errCodePrefix, "LT", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	devices, err := d.egressRepository.Device.GetByUserID(ctxVal, tokenInfo.UserID)
+	if err != nil {
+		logger.Error("Failed to fetch trusted devices", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(d.errCodePrefix, "LT", 2),
+			Message: "Failed to fetch trusted devices",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	msg := "Trusted devices fetched successfully"
+	if len(devices) == 0 {
+		msg = "No trusted devices found"
+		devices = nil
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).SetPayload(devices).Send(ctx)
+}
+
+func (d *deviceService) Revoke(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = d.logger.With(zap.String("requestID", reqID))
+		deviceID  = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, d.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(d.errCodePrefix, "RV", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := d.egressRepository.Device.DeleteByID(ctxVal, deviceID, tokenInfo.UserID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Device '%s' not found", deviceID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(d.errCodePrefix, "RV", 2),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to revoke trusted device", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(d.errCodePrefix, "RV", 3),
+			Message: "Failed to revoke trusted device",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).
+		SetMessage(fmt.Sprintf("Device '%s' revoked successfully", deviceID)).Send(ctx)
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/valyala/fasthttp"
+)
+
+// dpopAlgorithms are the asymmetric algorithms accepted for proofs
+var dpopAlgorithms = []string{"ES256", "ES384", "RS256", "PS256", "EdDSA"}
+
+type dpopService struct {
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewDpopService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.DpopServicePorts {
+	return &dpopService{
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (s *dpopService) Enabled() bool {
+	return s.config.Dpop != nil && s.config.Dpop.Enabled && s.egressRepository.Replay != nil
+}
+
+// dpopClaims are the claims of a proof, they are checked by VerifyProof rather than by the parser
+type dpopClaims struct {
+	ID        string           `json:"jti"`
+	Method    string           `json:"htm"`
+	URI       string           `json:"htu"`
+	IssuedAt  *jwt.NumericDate `json:"iat"`
+	TokenHash string           `json:"ath,omitempty"`
+}
+
+func (c *dpopClaims) Valid() error {
+	return nil
+}
+
+func (s *dpopService) VerifyProof(ctx *fasthttp.RequestCtx, accessToken string) (string, error) {
+	if !s.Enabled() {
+		return "", utils.ErrInvalidDpopProof
+	}
+
+	// Exactly one proof per request
+	proofs := ctx.Request.Header.PeekAll(constants.Dpop.String())
+	if len(proofs) != 1 {
+		return "", utils.ErrInvalidDpopProof
+	}
+
+	var (
+		claims     dpopClaims
+		thumbprint string
+		parser     = jwt.NewParser(jwt.WithValidMethods(dpopAlgorithms), jwt.WithoutClaimsValidation())
+	)
+
+	_, err := parser.ParseWithClaims(string(proofs[0]), &claims, func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); typ != constants.DpopProofType {
+			return nil, fmt.Errorf("unexpected typ %q", typ)
+		}
+
+		jwk, ok := t.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("missing jwk header")
+		}
+
+		key, jkt, err := jwkPublicKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		thumbprint = jkt
+		return key, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", utils.ErrInvalidDpopProof, err)
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return "", fmt.Errorf("%w: missing jti or iat", utils.ErrInvalidDpopProof)
+	}
+
+	if claims.Method != string(ctx.Method()) || claims.URI != s.targetURI(ctx) {
+		return "", fmt.Errorf("%w: htm or htu does not match the request", utils.ErrInvalidDpopProof)
+	}
+
+	age := time.Since(claims.IssuedAt.Time)
+	if age < 0 {
+		age = -age
+	}
+	if age > s.config.Dpop.MaxProofAge {
+		return "", fmt.Errorf("%w: iat out of range", utils.ErrInvalidDpopProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if subtle.ConstantTimeCompare([]byte(claims.TokenHash), []byte(base64.RawURLEncoding.EncodeToString(sum[:]))) != 1 {
+			return "", fmt.Errorf("%w: ath does not match the access token", utils.ErrInvalidDpopProof)
+		}
+	}
+
+	// A proof is accepted once, it stays recorded for as long as its iat is acceptable
+	fresh, err := s.egressRepository.Replay.Consume(ctx, "dpop", thumbprint+":"+claims.ID, 2*s.config.Dpop.MaxProofAge)
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", fmt.Errorf("%w: proof replayed", utils.ErrInvalidDpopProof)
+	}
+
+	return thumbprint, nil
+}
+
+// targetURI is the htu expected for the request, the query is not part of it
+func (s *dpopService) targetURI(ctx *fasthttp.RequestCtx) string {
+	return strings.TrimSuffix(s.config.Dpop.BaseURL, "/") + string(ctx.Path())
+}
+
+// jwkPublicKey decodes the public JWK of a proof and computes its RFC 7638 thumbprint
+func jwkPublicKey(jwk map[string]interface{}) (crypto.PublicKey, string, error) {
+	member := func(name string) string {
+		value, _ := jwk[name].(string)
+		return value
+	}
+	decode := func(name string) ([]byte, error) {
+		value, err := base64.RawURLEncoding.DecodeString(member(name))
+		if err != nil || len(value) == 0 {
+			return nil, fmt.Errorf("invalid jwk member %q", name)
+		}
+		return value, nil
+	}
+
+	if _, found := jwk["d"]; found {
+		return nil, "", errors.New("jwk must not contain a private key")
+	}
+
+	var (
+		key       crypto.PublicKey
+		canonical string
+	)
+
+	switch member("kty") {
+	case "EC":
+		var curve elliptic.Curve
+		switch member("crv") {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, "", fmt.Errorf("unsupported curve %q", member("crv"))
+		}
+
+		x, err := decode("x")
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decode("y")
+		if err != nil {
+			return nil, "", err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, "", fmt.Errorf("invalid EC key: %w", err)
+		}
+		key = pub
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, member("crv"), member("x"), member("y"))
+	case "RSA":
+		n, err := decode("n")
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decode("e")
+		if err != nil {
+			return nil, "", err
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, "", errors.New("invalid RSA exponent")
+		}
+
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, "", errors.New("RSA keys must be at least 2048 bits")
+		}
+		key = pub
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, member("e"), member("n"))
+	case "OKP":
+		if member("crv") != "Ed25519" {
+			return nil, "", fmt.Errorf("unsupported curve %q", member("crv"))
+		}
+
+		x, err := decode("x")
+		if err != nil {
+			return nil, "", err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("invalid Ed25519 key")
+		}
+		key = ed25519.PublicKey(x)
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, member("x"))
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %q", member("kty"))
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return key, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type mtlsService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+	clientCAs        *x509.CertPool
+}
+
+// NewMtlsService needs the CA pool to verify certificates forwarded by a proxy, the TLS
+// handshake has already verified the others
+func NewMtlsService(config *models.Config, logger ports.Logger, egressRepository egress.Repository, clientCAs *x509.CertPool) ingress.MtlsServicePorts {
+	return &mtlsService{
+		errCodePrefix:    "MT-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+		clientCAs:        clientCAs,
+	}
+}
+
+func (s *mtlsService) Enabled() bool {
+	return s.config.Mtls != nil && s.config.Mtls.Enabled && s.clientCAs != nil
+}
+
+func (s *mtlsService) Certificate(ctx *fasthttp.RequestCtx) *x509.Certificate {
+	if !s.Enabled() {
+		return nil
+	}
+
+	if s.config.Mtls.CertHeader != "" {
+		// Certificates are public, the header only proves anything when the proxy set it
+		server := s.config.App.Server
+		if !server.TrustProxy || !utils.IPInCIDRs(ctx.RemoteIP().String(), server.TrustedProxies) {
+			return nil
+		}
+		return s.forwardedCertificate(ctx)
+	}
+
+	if !ctx.IsTLS() {
+		return nil
+	}
+
+	state := ctx.TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// forwardedCertificate parses and verifies the certificate the proxy put in the configured header
+func (s *mtlsService) forwardedCertificate(ctx *fasthttp.RequestCtx) *x509.Certificate {
+	header := ctx.Request.Header.Peek(s.config.Mtls.CertHeader)
+	if len(header) == 0 {
+		return nil
+	}
+
+	decoded, err := url.QueryUnescape(string(header))
+	if err != nil {
+		return nil
+	}
+
+	block, _ := pem.Decode([]byte(decoded))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     s.clientCAs,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		s.logger.Info("forwarded client certificate rejected", zap.String("subject", cert.Subject.String()), zap.Error(err))
+		return nil
+	}
+	return cert
+}
+
+// Authenticate finds the binding of the certificate, the subject is tried first, then the SANs
+func (s *mtlsService) Authenticate(ctx context.Context, cert *x509.Certificate) (*models.CertificateBinding, error) {
+	if cert == nil {
+		return nil, utils.ErrInvalidCertificate
+	}
+
+	for _, identity := range certificateIdentities(cert) {
+		binding, err := s.egressRepository.Certificate.GetByMatch(ctx, identity.match, identity.value)
+		if err == nil {
+			return binding, nil
+		}
+		if !errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, utils.ErrInvalidCertificate
+}
+
+type certificateIdentity struct {
+	match constants.CertificateMatch
+	value string
+}
+
+func certificateIdentities(cert *x509.Certificate) []certificateIdentity {
+	identities := []certificateIdentity{{constants.CertMatchSubject, cert.Subject.String()}}
+	for _, name := range cert.DNSNames {
+		identities = append(identities, certificateIdentity{constants.CertMatchDNS, name})
+	}
+	for _, email := range cert.EmailAddresses {
+		identities = append(identities, certificateIdentity{constants.CertMatchEmail, email})
+	}
+	for _, uri := range cert.URIs {
+		identities = append(identities, certificateIdentity{constants.CertMatchURI, uri.String()})
+	}
+	return identities
+}
+
+func (s *mtlsService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	bindings, err := s.egressRepository.Certificate.List(ctxVal)
+	if err != nil {
+		logger.Error("Failed to fetch certificate bindings", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 1),
+			Message: "Failed to fetch certificate bindings",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	msg := "Certificate bindings fetched successfully"
+	if len(bindings) == 0 {
+		msg = "No certificate bindings found"
+		bindings = nil
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).SetPayload(bindings).Send(ctx)
+}
+
+// Add binds a certificate identity to an existing service account or user
+func (s *mtlsService) Add(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		payload  models.CertificateBindingRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode certificate binding request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	// The user repository answers an unknown user without an error, the service account one with
+	// ErrDocumentNotFound
+	var (
+		found bool
+		err   error
+	)
+	if payload.ServiceAccountID != "" {
+		var account *models.ServiceAccount
+		account, err = s.egressRepository.ServiceAccount.GetByID(ctxVal, payload.ServiceAccountID)
+		found = account != nil
+	} else {
+		var user *models.User
+		user, err = s.egressRepository.User.GetByID(ctxVal, payload.UserID)
+		found = user != nil
+	}
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		logger.Error("Failed to fetch bound principal", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 4),
+			Message: "Failed to create certificate binding",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if !found {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 3),
+			Message: "Bound principal not found",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	binding := &models.CertificateBinding{
+		ID:               uuid.NewString(),
+		Match:            payload.Match,
+		Value:            payload.Value,
+		ServiceAccountID: payload.ServiceAccountID,
+		UserID:           payload.UserID,
+		CreatedBy:        actorOf(ctx),
+		CreatedAt:        time.Now(),
+	}
+
+	if err := s.egressRepository.Certificate.Add(ctxVal, binding); err != nil {
+		if errors.Is(err, utils.ErrDuplicate) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "AD", 5),
+				Message: fmt.Sprintf("A binding for %s '%s' already exists", payload.Match, payload.Value),
+				Detail:  err,
+			}).SetStatusCode(http.StatusConflict).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to create certificate binding", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 6),
+			Message: "Failed to create certificate binding",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Certificate binding created successfully").SetPayload(binding).Send(ctx)
+}
+
+func (s *mtlsService) Delete(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		bindingID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Certificate.DeleteByID(ctxVal, bindingID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Certificate binding '%s' not found", bindingID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "DE", 1),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to delete certificate binding", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 2),
+			Message: "Failed to delete certificate binding",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Certificate binding '%s' deleted successfully", bindingID)).Send(ctx)
+}
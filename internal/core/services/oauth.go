@@ -0,0 +1,1665 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// oauthService lets applications sign users in through the gateway. The SSO cookie points at
+// the gateway session, so every application after the first one signs in without credentials.
+type oauthService struct {
+	errCodePrefix     string
+	config            *models.Config
+	logger            ports.Logger
+	egressRepository  egress.Repository
+	ingressRepository ingress.Repository
+}
+
+func NewOAuthService(config *models.Config, logger ports.Logger, egressRepository egress.Repository, ingressRepository ingress.Repository) ingress.OAuthServicePorts {
+	return &oauthService{
+		errCodePrefix:     "OA-%s-%d",
+		config:            config,
+		logger:            logger,
+		egressRepository:  egressRepository,
+		ingressRepository: ingressRepository,
+	}
+}
+
+func (s *oauthService) Enabled() bool {
+	return s.config.Sso != nil && s.config.Sso.Enabled && s.ingressRepository.Session.Enabled()
+}
+
+// SetSsoCookie points the SSO cookie at the session, it expires together with the session
+func (s *oauthService) SetSsoCookie(ctx *fasthttp.RequestCtx, sessionID string) {
+	if !s.Enabled() || sessionID == "" {
+		return
+	}
+
+	session, err := s.ingressRepository.Session.Get(ctx, sessionID)
+	if err != nil {
+		s.logger.Error("Failed to fetch session for the SSO cookie", zap.String("sessionID", sessionID), zap.Error(err))
+		return
+	}
+
+	cnf := s.config.Sso
+	value := session.ID + "." + utils.Sign(cnf.SecretKey, session.ID)
+	setCookie(ctx, cnf.CookieName, value, cnf.CookieDomain, cnf.CookieSecure, true, fasthttp.CookieSameSiteLaxMode, session.ExpiresAt)
+}
+
+func (s *oauthService) ClearSsoCookie(ctx *fasthttp.RequestCtx) {
+	if !s.Enabled() {
+		return
+	}
+
+	cnf := s.config.Sso
+	setCookie(ctx, cnf.CookieName, "", cnf.CookieDomain, cnf.CookieSecure, true, fasthttp.CookieSameSiteLaxMode, time.Unix(0, 0))
+}
+
+// ssoSession returns the live session of the SSO cookie, nil when there is none
+func (s *oauthService) ssoSession(ctx *fasthttp.RequestCtx) (*models.Session, error) {
+	cookie := string(ctx.Request.Header.Cookie(s.config.Sso.CookieName))
+	sessionID, signature, found := strings.Cut(cookie, ".")
+	if !found || sessionID == "" {
+		return nil, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(utils.Sign(s.config.Sso.SecretKey, sessionID))) != 1 {
+		return nil, nil
+	}
+
+	session, err := s.ingressRepository.Session.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSession) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Authorize issues an authorization code for the SSO session, or sends the browser to the login
+// page when there is none, when prompt=login asks for a new authentication or when the last
+// authentication is older than max_age
+func (s *oauthService) Authorize(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		args     = ctx.QueryArgs()
+
+		clientID            = string(args.Peek("client_id"))
+		redirectURI         = string(args.Peek("redirect_uri"))
+		responseType        = string(args.Peek("response_type"))
+		state               = string(args.Peek("state"))
+		prompt              = string(args.Peek("prompt"))
+		codeChallenge       = string(args.Peek("code_challenge"))
+		codeChallengeMethod = string(args.Peek("code_challenge_method"))
+	)
+
+	if !s.Enabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AZ", 1),
+			Message: "Single sign-on is not enabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	// Without a known client and redirect URI there is nowhere safe to send errors to
+	client, err := s.egressRepository.Client.GetByID(ctxVal, clientID)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		logger.Error("Failed to fetch client", zap.String("clientID", clientID), zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AZ", 2),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if client == nil || client.Status != constants.StatusActive || !client.AllowsRedirect(redirectURI) {
+		logger.Info("unknown client or redirect uri", zap.String("clientID", clientID), zap.String("redirectURI", redirectURI))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AZ", 3),
+			Message: "Unknown client or redirect URI",
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	if responseType != constants.ResponseTypeCode {
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthUnsupportedResponseType}}, state)
+		return
+	}
+
+	if codeChallenge != "" && codeChallengeMethod != constants.CodeChallengeS256 {
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthInvalidRequest}, "error_description": {"code_challenge_method must be S256"}}, state)
+		return
+	}
+
+	if codeChallenge == "" && !client.Confidential() {
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthInvalidRequest}, "error_description": {"code_challenge is required"}}, state)
+		return
+	}
+
+	maxAge := -1
+	if raw := args.Peek("max_age"); len(raw) > 0 {
+		if maxAge, err = strconv.Atoi(string(raw)); err != nil || maxAge < 0 {
+			s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthInvalidRequest}, "error_description": {"max_age must be a non negative integer"}}, state)
+			return
+		}
+	}
+
+	session, err := s.ssoSession(ctx)
+	if err != nil {
+		logger.Error("Failed to fetch SSO session", zap.Error(err))
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthServerError}}, state)
+		return
+	}
+
+	loginRequired := session == nil || prompt == constants.PromptLogin ||
+		(maxAge >= 0 && time.Since(session.AuthTime) > time.Duration(maxAge)*time.Second)
+
+	if loginRequired {
+		if prompt == constants.PromptNone {
+			s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthLoginRequired}}, state)
+			return
+		}
+
+		s.login(ctx, response, logger)
+		return
+	}
+
+	code, err := utils.RandomToken(32)
+	if err != nil {
+		logger.Error("Failed to generate authorization code", zap.Error(err))
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthServerError}}, state)
+		return
+	}
+
+	authCode := &models.AuthorizationCode{
+		ClientID:      client.ID,
+		RedirectURI:   redirectURI,
+		SessionID:     session.ID,
+		UserID:        session.UserID,
+		CodeChallenge: codeChallenge,
+		AuthTime:      session.AuthTime,
+		ExpiresAt:     time.Now().Add(s.config.Sso.CodeLifeSpan),
+	}
+
+	key := fmt.Sprintf(constants.CacheKeyAuthCode, utils.HashToken(code))
+	if err := s.egressRepository.Cache.Add(ctxVal, key, authCode, s.config.Sso.CodeLifeSpan, constants.CacheAdd); err != nil {
+		logger.Error("Failed to store authorization code", zap.Error(err))
+		s.redirect(ctx, logger, redirectURI, url.Values{"error": {constants.OAuthServerError}}, state)
+		return
+	}
+
+	if err := s.egressRepository.Session.AddClient(ctxVal, session.ID, client.ID); err != nil {
+		logger.Error("Failed to record client on session", zap.String("sessionID", session.ID), zap.String("clientID", client.ID), zap.Error(err))
+	}
+
+	s.redirect(ctx, logger, redirectURI, url.Values{"code": {code}}, state)
+}
+
+// login sends the browser to the login page, which resumes the authorization request once the
+// user signed in. The prompt is dropped so the resumed request does not ask for login again.
+func (s *oauthService) login(ctx *fasthttp.RequestCtx, response ports.Response, logger ports.Logger) {
+	returnTo := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(returnTo)
+
+	ctx.URI().CopyTo(returnTo)
+	returnTo.QueryArgs().Del("prompt")
+
+	loginURL, err := url.Parse(s.config.Sso.LoginURL)
+	if err != nil {
+		logger.Error("Invalid SSO login url", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AZ", 4),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	query := loginURL.Query()
+	query.Set("return_to", returnTo.String())
+	loginURL.RawQuery = query.Encode()
+
+	ctx.Redirect(loginURL.String(), http.StatusFound)
+}
+
+// redirect answers an authorization request on the redirect URI of the client
+func (s *oauthService) redirect(ctx *fasthttp.RequestCtx, logger ports.Logger, redirectURI string, params url.Values, state string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		logger.Error("Invalid redirect uri", zap.String("redirectURI", redirectURI), zap.Error(err))
+		ctx.SetStatusCode(http.StatusBadRequest)
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+
+	ctx.Response.Header.Set(constants.CacheControl.String(), "no-store")
+	ctx.Redirect(target.String(), http.StatusFound)
+}
+
+// Token exchanges an authorization code for an access token of the SSO session
+func (s *oauthService) Token(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID  = utils.GetField(ctx, constants.CtxRequestID)
+		logger = s.logger.With(zap.String("requestID", reqID))
+		args   = ctx.PostArgs()
+	)
+
+	cnf, ok := s.confirmation(ctx, logger)
+	if !ok {
+		return
+	}
+
+	switch string(args.Peek("grant_type")) {
+	case constants.GrantAuthorizationCode:
+		if !s.Enabled() {
+			writeOAuth(ctx, http.StatusNotFound, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "single sign-on is not enabled"})
+			return
+		}
+		s.authorizationCodeGrant(ctx, logger, cnf)
+	case constants.GrantClientCredentials:
+		assertionType := string(args.Peek("client_assertion_type"))
+		switch {
+		case assertionType == constants.ClientAssertionJwtBearer:
+			s.serviceAccountGrant(ctx, logger, string(args.Peek("client_assertion")), cnf)
+		case assertionType == "" && s.ingressRepository.Mtls.Enabled():
+			s.certificateGrant(ctx, logger, cnf)
+		default:
+			writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient, ErrorDescription: "a private key JWT client assertion or a client certificate is required"})
+		}
+	case constants.GrantJwtBearer:
+		s.serviceAccountGrant(ctx, logger, string(args.Peek("assertion")), cnf)
+	case constants.GrantDeviceCode:
+		s.deviceCodeGrant(ctx, logger, cnf)
+	case constants.GrantTokenExchange:
+		s.tokenExchangeGrant(ctx, logger, cnf)
+	default:
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnsupportedGrantType})
+	}
+}
+
+// Introspect tells a confidential client whether a JWT or opaque token is active, see RFC 7662
+func (s *oauthService) Introspect(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID  = utils.GetField(ctx, constants.CtxRequestID)
+		logger = s.logger.With(zap.String("requestID", reqID))
+	)
+
+	client, ok := s.authenticateClient(ctx, logger)
+	if !ok {
+		return
+	}
+	if !client.Confidential() {
+		writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient, ErrorDescription: "only confidential clients may introspect tokens"})
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	tokenInfo, err := s.ingressRepository.Token.ResolveToken(ctxVal, string(ctx.PostArgs().Peek("token")))
+	if err == nil {
+		_, err = s.ingressRepository.Session.Validate(ctxVal, tokenInfo)
+	}
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidToken) && !errors.Is(err, utils.ErrInvalidSession) {
+			logger.Error("Failed to introspect token", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		writeOAuth(ctx, http.StatusOK, &models.Introspection{Active: false})
+		return
+	}
+
+	permissions := make([]string, 0, len(tokenInfo.Permissions))
+	for permission := range tokenInfo.Permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+
+	introspection := &models.Introspection{
+		Active:           true,
+		Scope:            strings.Join(permissions, " "),
+		TokenType:        tokenType(tokenInfo.Cnf),
+		Subject:          strconv.Itoa(tokenInfo.UserID),
+		Audience:         tokenInfo.Audience,
+		Issuer:           tokenInfo.Issuer,
+		ID:               tokenInfo.ID,
+		UserID:           tokenInfo.UserID,
+		Role:             tokenInfo.Role,
+		SessionID:        tokenInfo.SessionID,
+		ServiceAccountID: tokenInfo.ServiceAccountID,
+		Cnf:              tokenInfo.Cnf,
+		Act:              tokenInfo.Act,
+		Custom:           tokenInfo.Custom,
+	}
+	if tokenInfo.ServiceAccountID != "" {
+		introspection.Subject = tokenInfo.ServiceAccountID
+	}
+	if tokenInfo.ExpiresAt != nil {
+		introspection.ExpiresAt = tokenInfo.ExpiresAt.Unix()
+	}
+	if tokenInfo.IssuedAt != nil {
+		introspection.IssuedAt = tokenInfo.IssuedAt.Unix()
+	}
+
+	writeOAuth(ctx, http.StatusOK, introspection)
+}
+
+// Revoke ends a token issued to the client, see RFC 7009. Opaque tokens stop working at once,
+// JWTs are denied until they expire. Unknown tokens are not an error.
+func (s *oauthService) Revoke(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID  = utils.GetField(ctx, constants.CtxRequestID)
+		logger = s.logger.With(zap.String("requestID", reqID))
+		token  = string(ctx.PostArgs().Peek("token"))
+	)
+
+	client, ok := s.authenticateClient(ctx, logger)
+	if !ok {
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	tokenInfo, err := s.ingressRepository.Token.ResolveToken(ctxVal, token)
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidToken) {
+			logger.Error("Failed to resolve revoked token", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		ctx.SetStatusCode(http.StatusOK)
+		return
+	}
+
+	// Clients only revoke their own tokens
+	if !slices.Contains(tokenInfo.Audience, client.ID) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnauthorizedClient, ErrorDescription: "the token was not issued to the client"})
+		return
+	}
+
+	if err := s.ingressRepository.Token.RevokeToken(ctxVal, token); err != nil {
+		logger.Error("Failed to revoke token", zap.String("clientID", client.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	logger.Info("token revoked", zap.String("clientID", client.ID), zap.String("tokenID", tokenInfo.ID))
+	ctx.SetStatusCode(http.StatusOK)
+}
+
+// Jwks publishes the keys clients verify logout tokens with
+func (s *oauthService) Jwks(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID  = utils.GetField(ctx, constants.CtxRequestID)
+		logger = s.logger.With(zap.String("requestID", reqID))
+	)
+
+	keys, err := s.ingressRepository.Token.LogoutKeys()
+	if err != nil {
+		logger.Error("Failed to load logout signing key", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	writeOAuth(ctx, http.StatusOK, keys)
+	ctx.Response.Header.Set(constants.CacheControl.String(), "public, max-age=3600")
+}
+
+// serviceAccountGrant issues a token to the service account that signed the assertion
+func (s *oauthService) serviceAccountGrant(ctx *fasthttp.RequestCtx, logger ports.Logger, assertion string, cnf *models.Confirmation) {
+	if !s.ingressRepository.ServiceAccount.Enabled() {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnsupportedGrantType})
+		return
+	}
+
+	if assertion == "" {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "missing assertion"})
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	clientIP := utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies)
+	account, err := s.ingressRepository.ServiceAccount.Authenticate(ctxVal, assertion, clientIP)
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidAssertion) {
+			logger.Error("Failed to authenticate service account", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		logger.Info("service account assertion rejected", zap.Error(err))
+		writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient})
+		return
+	}
+
+	token, expiresIn, err := s.ingressRepository.ServiceAccount.IssueToken(ctxVal, account, clientIP, cnf)
+	if err != nil {
+		logger.Error("Service account token generation failed", zap.String("serviceAccountID", account.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	writeOAuth(ctx, http.StatusOK, &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   tokenType(cnf),
+		ExpiresIn:   int(expiresIn.Seconds()),
+	})
+}
+
+// certificateGrant issues a token to the service account or user the client certificate is bound to
+func (s *oauthService) certificateGrant(ctx *fasthttp.RequestCtx, logger ports.Logger, cnf *models.Confirmation) {
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	binding, err := s.ingressRepository.Mtls.Authenticate(ctxVal, s.ingressRepository.Mtls.Certificate(ctx))
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidCertificate) {
+			logger.Error("Failed to fetch certificate binding", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient, ErrorDescription: "client certificate missing or not bound"})
+		return
+	}
+
+	if binding.ServiceAccountID != "" {
+		if !s.ingressRepository.ServiceAccount.Enabled() {
+			writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnauthorizedClient})
+			return
+		}
+
+		account, err := s.egressRepository.ServiceAccount.GetByID(ctxVal, binding.ServiceAccountID)
+		if err != nil || account.Status != constants.StatusActive {
+			logger.Info("service account unavailable for token", zap.String("serviceAccountID", binding.ServiceAccountID), zap.Error(err))
+			writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient})
+			return
+		}
+
+		token, expiresIn, err := s.ingressRepository.ServiceAccount.IssueToken(ctxVal, account, utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), cnf)
+		if err != nil {
+			logger.Error("Service account token generation failed", zap.String("serviceAccountID", account.ID), zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+
+		writeOAuth(ctx, http.StatusOK, &models.TokenResponse{
+			AccessToken: token,
+			TokenType:   tokenType(cnf),
+			ExpiresIn:   int(expiresIn.Seconds()),
+		})
+		return
+	}
+
+	user, err := s.egressRepository.User.GetByID(ctxVal, binding.UserID)
+	if err == nil && user == nil {
+		logger.Info("certificate bound to a deleted user", zap.Int("userID", binding.UserID))
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "the bound user no longer exists"})
+		return
+	}
+	if err != nil || user.Status != constants.StatusActive {
+		logger.Info("user unavailable for token", zap.Int("userID", binding.UserID), zap.Error(err))
+		writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient})
+		return
+	}
+
+	permissions, err := userPermissions(ctxVal, s.egressRepository.Role, user)
+	if err != nil {
+		logger.Error("Failed to resolve user permissions", zap.Int("userID", user.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	token, err := s.ingressRepository.Token.IssueToken(ctxVal, user.Role, permissions, user, &models.TokenOptions{
+		Confirmation: cnf,
+	})
+	if err != nil {
+		logger.Error("Token generation failed", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	writeOAuth(ctx, http.StatusOK, &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   tokenType(cnf),
+		ExpiresIn:   int(s.config.Jwt.LifeSpan.Seconds()),
+	})
+}
+
+// confirmation collects the keys the issued token is bound to: the client certificate, when
+// configured and presented, and the key of the DPoP proof, when one is sent
+func (s *oauthService) confirmation(ctx *fasthttp.RequestCtx, logger ports.Logger) (*models.Confirmation, bool) {
+	var cnf models.Confirmation
+
+	if s.ingressRepository.Mtls.Enabled() && s.config.Mtls.BindTokens {
+		if cert := s.ingressRepository.Mtls.Certificate(ctx); cert != nil {
+			cnf.X5tS256 = utils.CertThumbprint(cert)
+		}
+	}
+
+	if s.ingressRepository.Dpop.Enabled() && len(ctx.Request.Header.Peek(constants.Dpop.String())) > 0 {
+		jkt, err := s.ingressRepository.Dpop.VerifyProof(ctx, "")
+		if err != nil {
+			if !errors.Is(err, utils.ErrInvalidDpopProof) {
+				logger.Error("Failed to verify DPoP proof", zap.Error(err))
+				writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+				return nil, false
+			}
+			logger.Info("DPoP proof rejected", zap.Error(err))
+			writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidDpopProof, ErrorDescription: err.Error()})
+			return nil, false
+		}
+		cnf.Jkt = jkt
+	}
+
+	if cnf == (models.Confirmation{}) {
+		return nil, true
+	}
+	return &cnf, true
+}
+
+// tokenType tells the client to present DPoP bound tokens with their proofs
+func tokenType(cnf *models.Confirmation) string {
+	if cnf != nil && cnf.Jkt != "" {
+		return constants.TokenTypeDpop
+	}
+	return constants.TokenTypeBearer
+}
+
+func (s *oauthService) authorizationCodeGrant(ctx *fasthttp.RequestCtx, logger ports.Logger, cnf *models.Confirmation) {
+	args := ctx.PostArgs()
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, ok := s.authenticateClient(ctx, logger)
+	if !ok {
+		return
+	}
+
+	var (
+		code     models.AuthorizationCode
+		rawCode  = string(args.Peek("code"))
+		verifier = string(args.Peek("code_verifier"))
+	)
+
+	// Codes are single use, a replayed code finds nothing
+	if err := s.egressRepository.Cache.GetDel(ctxVal, fmt.Sprintf(constants.CacheKeyAuthCode, utils.HashToken(rawCode)), &code); err != nil {
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			logger.Error("Failed to fetch authorization code", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "invalid or expired code"})
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != string(args.Peek("redirect_uri")) || time.Now().After(code.ExpiresAt) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "invalid or expired code"})
+		return
+	}
+
+	if code.CodeChallenge != "" && !validCodeVerifier(verifier, code.CodeChallenge) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "invalid code_verifier"})
+		return
+	}
+
+	s.sessionToken(ctx, ctxVal, logger, client, code.SessionID, code.UserID, cnf)
+}
+
+// sessionToken answers a token request with a token of the user bound to the gateway session
+func (s *oauthService) sessionToken(ctx *fasthttp.RequestCtx, ctxVal context.Context, logger ports.Logger, client *models.Client, sessionID string, userID int, cnf *models.Confirmation) {
+	session, err := s.ingressRepository.Session.Get(ctxVal, sessionID)
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidSession) {
+			logger.Error("Failed to fetch session", zap.String("sessionID", sessionID), zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "session expired or revoked"})
+		return
+	}
+
+	user, err := s.egressRepository.User.GetByID(ctxVal, userID)
+	if err != nil || user == nil || user.Status != constants.StatusActive {
+		logger.Info("user unavailable for token", zap.Int("userID", userID), zap.Error(err))
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "user is not active"})
+		return
+	}
+
+	permissions, err := userPermissions(ctxVal, s.egressRepository.Role, user)
+	if err != nil {
+		logger.Error("Failed to resolve user permissions", zap.Int("userID", user.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	token, err := s.ingressRepository.Token.IssueToken(ctxVal, user.Role, permissions, user, &models.TokenOptions{
+		SessionID:    session.ID,
+		NotAfter:     session.ExpiresAt,
+		Acr:          session.Acr,
+		Amr:          session.Amr,
+		AuthTime:     session.AuthTime,
+		Audience:     []string{client.ID},
+		Confirmation: cnf,
+		Format:       client.TokenFormat,
+		ClaimMapping: client.ClaimMapping,
+	})
+	if err != nil {
+		logger.Error("Token generation failed", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	expiresIn := s.config.Jwt.LifeSpan
+	if untilEnd := time.Until(session.ExpiresAt); untilEnd < expiresIn {
+		expiresIn = untilEnd
+	}
+
+	writeOAuth(ctx, http.StatusOK, &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   tokenType(cnf),
+		ExpiresIn:   int(expiresIn.Seconds()),
+	})
+}
+
+// authenticateClient checks the client credentials of the token request, sent either with HTTP
+// Basic or in the form. Public clients only send their id and prove themselves with PKCE.
+func (s *oauthService) authenticateClient(ctx *fasthttp.RequestCtx, logger ports.Logger) (*models.Client, bool) {
+	clientID, secret, found := basicAuth(ctx)
+	if !found {
+		clientID = string(ctx.PostArgs().Peek("client_id"))
+		secret = string(ctx.PostArgs().Peek("client_secret"))
+	}
+
+	client, err := s.egressRepository.Client.GetByID(ctx, clientID)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		logger.Error("Failed to fetch client", zap.String("clientID", clientID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return nil, false
+	}
+
+	if client == nil || client.Status != constants.StatusActive ||
+		(client.Confidential() && subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(client.ID, secret))) != 1) {
+		logger.Info("client authentication failed", zap.String("clientID", clientID))
+		writeOAuth(ctx, http.StatusUnauthorized, &models.OAuthError{Error: constants.OAuthInvalidClient})
+		return nil, false
+	}
+
+	return client, true
+}
+
+func basicAuth(ctx *fasthttp.RequestCtx) (string, string, bool) {
+	header := string(ctx.Request.Header.Peek(constants.Authorization))
+	encoded, found := strings.CutPrefix(header, "Basic ")
+	if !found {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+
+	clientID, secret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	return clientID, secret, true
+}
+
+// validCodeVerifier checks the PKCE verifier against its S256 challenge
+func validCodeVerifier(verifier, challenge string) bool {
+	if verifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// writeOAuth writes a body in the plain OAuth format, which clients expect instead of the
+// response envelope
+func writeOAuth(ctx *fasthttp.RequestCtx, statusCode int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		data = []byte(`{"error":"server_error"}`)
+	}
+
+	ctx.Response.Header.Set(constants.CacheControl.String(), "no-store")
+	ctx.SetContentType("application/json")
+	ctx.SetStatusCode(statusCode)
+	ctx.SetBody(data)
+}
+
+// NotifyLogout tells the clients of the session, or of every session of the user when
+// sessionID is empty, that it ended. Call it before the sessions are deleted.
+func (s *oauthService) NotifyLogout(ctx context.Context, userID int, sessionID string) {
+	if !s.Enabled() {
+		return
+	}
+
+	sessions, err := s.endingSessions(ctx, userID, sessionID)
+	if err != nil {
+		s.logger.Error("Failed to fetch sessions for logout", zap.Int("userID", userID), zap.Error(err))
+		return
+	}
+
+	s.backchannelLogout(sessions, s.sessionClients(ctx, sessions))
+}
+
+// Logout ends the SSO session of the browser. Clients with a back-channel endpoint are notified
+// directly, the returned page loads the front-channel endpoints of the others in iframes.
+func (s *oauthService) Logout(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	if !s.Enabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LO", 1),
+			Message: "Single sign-on is not enabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	session, err := s.ssoSession(ctx)
+	if err != nil {
+		logger.Error("Failed to fetch SSO session", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LO", 2),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	var frames []string
+	if session != nil {
+		sessions := []models.Session{*session}
+		clients := s.sessionClients(ctxVal, sessions)
+		s.backchannelLogout(sessions, clients)
+
+		for _, client := range clients {
+			if client.FrontchannelLogoutURI == "" {
+				continue
+			}
+			if frame, err := s.frontchannelURL(client.FrontchannelLogoutURI, session.ID); err == nil {
+				frames = append(frames, frame)
+			} else {
+				logger.Error("Invalid front-channel logout uri", zap.String("clientID", client.ID), zap.Error(err))
+			}
+		}
+
+		if err := s.ingressRepository.Session.End(ctxVal, &models.Token{UserID: session.UserID, SessionID: session.ID}); err != nil {
+			logger.Error("Failed to end SSO session", zap.String("sessionID", session.ID), zap.Error(err))
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "LO", 3),
+				Message: "Failed to sign out",
+			}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+			return
+		}
+	}
+
+	s.ClearSsoCookie(ctx)
+
+	var page strings.Builder
+	if err := logoutPage.Execute(&page, frames); err != nil {
+		logger.Error("Failed to render logout page", zap.Error(err))
+	}
+
+	ctx.Response.Header.Set(constants.CacheControl.String(), "no-store")
+	ctx.SetContentType(constants.Html.String())
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetBodyString(page.String())
+}
+
+var logoutPage = template.Must(template.New("logout").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Signed out</title></head>
+<body>
+<p>You have been signed out.</p>
+{{range .}}<iframe src="{{.}}" style="display:none"></iframe>
+{{end}}</body>
+</html>
+`))
+
+// frontchannelURL adds the issuer and session id, so the client can ignore unrelated requests
+func (s *oauthService) frontchannelURL(uri, sessionID string) (string, error) {
+	target, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	query := target.Query()
+	query.Set("iss", s.config.Jwt.Issuer)
+	query.Set("sid", sessionID)
+	target.RawQuery = query.Encode()
+
+	return target.String(), nil
+}
+
+func (s *oauthService) endingSessions(ctx context.Context, userID int, sessionID string) ([]models.Session, error) {
+	if sessionID == "" {
+		return s.egressRepository.Session.GetByUserID(ctx, userID)
+	}
+
+	session, err := s.ingressRepository.Session.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidSession) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return []models.Session{*session}, nil
+}
+
+// sessionClients returns the registered clients that signed in through any of the sessions
+func (s *oauthService) sessionClients(ctx context.Context, sessions []models.Session) map[string]*models.Client {
+	clients := make(map[string]*models.Client)
+	for _, session := range sessions {
+		for _, clientID := range session.Clients {
+			if _, found := clients[clientID]; found {
+				continue
+			}
+
+			client, err := s.egressRepository.Client.GetByID(ctx, clientID)
+			if err != nil {
+				if !errors.Is(err, utils.ErrDocumentNotFound) {
+					s.logger.Error("Failed to fetch client for logout", zap.String("clientID", clientID), zap.Error(err))
+				}
+				continue
+			}
+			clients[clientID] = client
+		}
+	}
+
+	return clients
+}
+
+// backchannelLogout posts a logout token to every client of the sessions that registered a
+// back-channel endpoint. Delivery runs in the background and is retried with a growing delay.
+func (s *oauthService) backchannelLogout(sessions []models.Session, clients map[string]*models.Client) {
+	for _, session := range sessions {
+		for _, clientID := range session.Clients {
+			client, found := clients[clientID]
+			if !found || client.BackchannelLogoutURI == "" {
+				continue
+			}
+
+			go s.sendLogoutToken(client, session.UserID, session.ID)
+		}
+	}
+}
+
+func (s *oauthService) sendLogoutToken(client *models.Client, userID int, sessionID string) {
+	logger := s.logger.With(zap.String("clientID", client.ID), zap.String("sessionID", sessionID))
+
+	token, err := s.ingressRepository.Token.GenerateLogoutToken(client.ID, userID, sessionID)
+	if err != nil {
+		logger.Error("Failed to generate logout token", zap.Error(err))
+		return
+	}
+
+	form := url.Values{"logout_token": {token}}
+	delay := s.config.Sso.LogoutRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := s.egressRepository.HttpClient.ExecuteForm(client.BackchannelLogoutURI, form, nil)
+		if err == nil {
+			return
+		}
+
+		if attempt >= s.config.Sso.LogoutRetries {
+			logger.Error("Back-channel logout failed", zap.Int("attempts", attempt+1), zap.Error(err))
+			return
+		}
+
+		logger.Warn("Back-channel logout failed, retrying", zap.Int("attempt", attempt+1), zap.Error(err))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// userCodeLength is the length of a user code without its separator
+const userCodeLength = 8
+
+func (s *oauthService) deviceEnabled() bool {
+	return s.Enabled() && s.config.DeviceFlow != nil && s.config.DeviceFlow.Enabled
+}
+
+// DeviceAuthorization starts the device flow of RFC 8628. The device shows the user code and polls
+// the token endpoint while the user approves the request on another device.
+func (s *oauthService) DeviceAuthorization(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID  = utils.GetField(ctx, constants.CtxRequestID)
+		logger = s.logger.With(zap.String("requestID", reqID))
+		cfg    = s.config.DeviceFlow
+	)
+
+	if !s.deviceEnabled() {
+		writeOAuth(ctx, http.StatusNotFound, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "device authorization is not enabled"})
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, ok := s.authenticateClient(ctx, logger)
+	if !ok {
+		return
+	}
+
+	deviceCode, err := utils.RandomToken(32)
+	if err != nil {
+		logger.Error("Failed to generate device code", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	userCode, err := s.newUserCode(ctxVal)
+	if err != nil {
+		logger.Error("Failed to generate user code", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	var (
+		deviceHash    = utils.HashToken(deviceCode)
+		authorization = &models.DeviceAuthorization{
+			ClientID:  client.ID,
+			UserCode:  userCode,
+			Status:    constants.DevicePending,
+			Interval:  int(cfg.PollInterval.Seconds()),
+			ExpiresAt: time.Now().Add(cfg.CodeLifeSpan),
+		}
+	)
+
+	if err := s.egressRepository.Cache.Add(ctxVal, fmt.Sprintf(constants.CacheKeyDeviceCode, deviceHash), authorization, cfg.CodeLifeSpan, constants.CacheAdd); err != nil {
+		logger.Error("Failed to store device authorization", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	if err := s.egressRepository.Cache.Add(ctxVal, fmt.Sprintf(constants.CacheKeyUserCode, userCode), deviceHash, cfg.CodeLifeSpan, constants.CacheAdd); err != nil {
+		logger.Error("Failed to store user code", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	complete, err := url.Parse(cfg.VerificationURI)
+	if err != nil {
+		logger.Error("Invalid device verification uri", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+	query := complete.Query()
+	query.Set("user_code", formatUserCode(userCode))
+	complete.RawQuery = query.Encode()
+
+	writeOAuth(ctx, http.StatusOK, &models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         cfg.VerificationURI,
+		VerificationURIComplete: complete.String(),
+		ExpiresIn:               int(cfg.CodeLifeSpan.Seconds()),
+		Interval:                authorization.Interval,
+	})
+}
+
+// newUserCode returns a user code no pending request uses
+func (s *oauthService) newUserCode(ctx context.Context) (string, error) {
+	for range 3 {
+		code, err := utils.UserCode(userCodeLength)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyUserCode, code), nil)
+		if errors.Is(err, utils.ErrInvalidCacheKey) {
+			return code, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("no free user code found")
+}
+
+// formatUserCode splits the user code in two halves, which is easier to read out
+func formatUserCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
+
+// pendingDevice finds the pending request of a user code, nil when there is none
+func (s *oauthService) pendingDevice(ctx context.Context, userCode string) (*models.DeviceAuthorization, string, error) {
+	var deviceHash string
+	if _, err := s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyUserCode, userCode), &deviceHash); err != nil {
+		if errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	var authorization models.DeviceAuthorization
+	if _, err := s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyDeviceCode, deviceHash), &authorization); err != nil {
+		if errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	if authorization.Status != constants.DevicePending || time.Now().After(authorization.ExpiresAt) {
+		return nil, "", nil
+	}
+	return &authorization, deviceHash, nil
+}
+
+// DeviceLookup shows the signed in user which application asks for access before they approve it
+func (s *oauthService) DeviceLookup(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		userCode = models.NormalizeUserCode(string(ctx.QueryArgs().Peek("user_code")))
+	)
+
+	if !s.deviceEnabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DL", 1),
+			Message: "Device authorization is not enabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	authorization, _, err := s.pendingDevice(ctxVal, userCode)
+	if err != nil {
+		logger.Error("Failed to fetch device authorization", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DL", 2),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if authorization == nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DL", 3),
+			Message: "Invalid or expired code",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	request := &models.DeviceRequest{
+		ClientID:  authorization.ClientID,
+		UserCode:  formatUserCode(authorization.UserCode),
+		ExpiresAt: authorization.ExpiresAt,
+	}
+	if client, err := s.egressRepository.Client.GetByID(ctxVal, authorization.ClientID); err == nil {
+		request.ClientName = client.Name
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Device request found").SetPayload(request).Send(ctx)
+}
+
+// DeviceVerify approves or denies a device request for the session of the signed in user
+func (s *oauthService) DeviceVerify(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+		payload   models.DeviceVerifyRequest
+	)
+
+	if !s.deviceEnabled() {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 1),
+			Message: "Device authorization is not enabled",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	// The device token shares the session, so it ends when the user signs out
+	if tokenInfo == nil || tokenInfo.SessionID == "" {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 2),
+			Message: "A signed in session is required to approve a device",
+		}).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode device verify request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 3),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 4),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	authorization, deviceHash, err := s.pendingDevice(ctxVal, payload.UserCode)
+	if err != nil {
+		logger.Error("Failed to fetch device authorization", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 5),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if authorization == nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 6),
+			Message: "Invalid or expired code",
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	authorization.Status = constants.DeviceDenied
+	if payload.Approve {
+		authorization.Status = constants.DeviceApproved
+		authorization.SessionID = tokenInfo.SessionID
+		authorization.UserID = tokenInfo.UserID
+	}
+
+	if err := s.egressRepository.Cache.Add(ctxVal, fmt.Sprintf(constants.CacheKeyDeviceCode, deviceHash), authorization, time.Until(authorization.ExpiresAt), constants.CacheUpdate); err != nil {
+		logger.Error("Failed to update device authorization", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DV", 7),
+			Message: "Something went wrong! Please try after sometime",
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	// A user code is answered once
+	if err := s.egressRepository.Cache.Delete(ctxVal, fmt.Sprintf(constants.CacheKeyUserCode, payload.UserCode)); err != nil {
+		logger.Error("Failed to delete user code", zap.Error(err))
+	}
+
+	msg := "Device sign in denied"
+	if payload.Approve {
+		if err := s.egressRepository.Session.AddClient(ctxVal, tokenInfo.SessionID, authorization.ClientID); err != nil {
+			logger.Error("Failed to record client on session", zap.String("sessionID", tokenInfo.SessionID), zap.String("clientID", authorization.ClientID), zap.Error(err))
+		}
+		msg = "Device sign in approved"
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).Send(ctx)
+}
+
+// deviceCodeGrant answers the polls of a device, with a token once the user approved the request
+func (s *oauthService) deviceCodeGrant(ctx *fasthttp.RequestCtx, logger ports.Logger, cnf *models.Confirmation) {
+	if !s.deviceEnabled() {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnsupportedGrantType})
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, ok := s.authenticateClient(ctx, logger)
+	if !ok {
+		return
+	}
+
+	var (
+		authorization models.DeviceAuthorization
+		deviceHash    = utils.HashToken(string(ctx.PostArgs().Peek("device_code")))
+		key           = fmt.Sprintf(constants.CacheKeyDeviceCode, deviceHash)
+		now           = time.Now()
+	)
+
+	if _, err := s.egressRepository.Cache.Get(ctxVal, key, &authorization); err != nil {
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			logger.Error("Failed to fetch device authorization", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthExpiredToken})
+		return
+	}
+
+	if authorization.ClientID != client.ID {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant})
+		return
+	}
+
+	if now.After(authorization.ExpiresAt) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthExpiredToken})
+		return
+	}
+
+	switch authorization.Status {
+	case constants.DeviceDenied:
+		if err := s.egressRepository.Cache.Delete(ctxVal, key); err != nil {
+			logger.Error("Failed to delete device authorization", zap.Error(err))
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthAccessDenied})
+	case constants.DeviceApproved:
+		// Only one poll consumes the approval
+		if err := s.egressRepository.Cache.GetDel(ctxVal, key, &authorization); err != nil {
+			if !errors.Is(err, utils.ErrInvalidCacheKey) {
+				logger.Error("Failed to consume device authorization", zap.Error(err))
+				writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+				return
+			}
+			writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthExpiredToken})
+			return
+		}
+		s.sessionToken(ctx, ctxVal, logger, client, authorization.SessionID, authorization.UserID, cnf)
+	default:
+		var (
+			poll    = models.DevicePoll{Interval: authorization.Interval}
+			pollKey = fmt.Sprintf(constants.CacheKeyDevicePoll, deviceHash)
+		)
+		if _, err := s.egressRepository.Cache.Get(ctxVal, pollKey, &poll); err != nil && !errors.Is(err, utils.ErrInvalidCacheKey) {
+			logger.Error("Failed to fetch device poll", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return
+		}
+
+		// Polling faster than the interval adds 5 seconds to it, see RFC 8628 section 3.5
+		errorCode := constants.OAuthAuthorizationPending
+		if now.Sub(poll.LastPolledAt) < time.Duration(poll.Interval)*time.Second {
+			errorCode = constants.OAuthSlowDown
+			poll.Interval += 5
+		}
+		poll.LastPolledAt = now
+
+		if err := s.egressRepository.Cache.Add(ctxVal, pollKey, &poll, time.Until(authorization.ExpiresAt), constants.CacheUpdate); err != nil {
+			logger.Error("Failed to update device poll", zap.Error(err))
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: errorCode})
+	}
+}
+
+// impersonationLimit caps the impersonation audit trail returned by the API
+const impersonationLimit = 200
+
+func (s *oauthService) exchangeEnabled() bool {
+	return s.config.TokenExchange != nil && s.config.TokenExchange.Enabled
+}
+
+// tokenExchangeGrant implements RFC 8693. Without requested_subject the subject token is narrowed,
+// on behalf of the actor when an actor token is sent. With it, the actor impersonates that user.
+func (s *oauthService) tokenExchangeGrant(ctx *fasthttp.RequestCtx, logger ports.Logger, cnf *models.Confirmation) {
+	args := ctx.PostArgs()
+
+	if !s.exchangeEnabled() {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnsupportedGrantType})
+		return
+	}
+
+	if requested := string(args.Peek("requested_token_type")); requested != "" && requested != constants.TokenTypeAccessToken && requested != constants.TokenTypeJwt {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "unsupported requested_token_type"})
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var actor *models.Token
+	if actorToken := string(args.Peek("actor_token")); actorToken != "" {
+		var ok bool
+		if actor, ok = s.exchangedToken(ctx, ctxVal, logger, actorToken, string(args.Peek("actor_token_type")), cnf); !ok {
+			return
+		}
+	}
+
+	if requestedSubject := string(args.Peek("requested_subject")); requestedSubject != "" {
+		s.impersonate(ctx, ctxVal, logger, actor, requestedSubject, cnf)
+		return
+	}
+
+	subject, ok := s.exchangedToken(ctx, ctxVal, logger, string(args.Peek("subject_token")), string(args.Peek("subject_token_type")), cnf)
+	if !ok {
+		return
+	}
+
+	opts := &models.TokenOptions{
+		SessionID:        subject.SessionID,
+		NotAfter:         subject.ExpiresAt.Time,
+		Acr:              subject.Acr,
+		Amr:              subject.Amr,
+		ServiceAccountID: subject.ServiceAccountID,
+		Audience:         audiences(args),
+		Confirmation:     cnf,
+		Actor:            subject.Act,
+	}
+	if subject.AuthTime != nil {
+		opts.AuthTime = subject.AuthTime.Time
+	}
+
+	if actor != nil {
+		if !actor.HasPermission(constants.PrmTokenExchange) {
+			writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnauthorizedClient, ErrorDescription: "the actor may not exchange tokens"})
+			return
+		}
+		opts.Actor = actingParty(actor, subject.Act)
+	}
+
+	permissions := make([]string, 0, len(subject.Permissions))
+	for permission := range subject.Permissions {
+		permissions = append(permissions, permission)
+	}
+	if opts.Actor != nil {
+		permissions = withoutAdminWrites(permissions)
+	}
+
+	token, expiresIn, permissions, ok := s.issueExchanged(ctx, logger, subject.Role, subject.UserID, permissions, opts)
+	if !ok {
+		return
+	}
+
+	s.writeExchangedToken(ctx, token, expiresIn, permissions, cnf)
+}
+
+// exchangedToken validates a subject or actor token. A sender constrained token is only accepted
+// when the request proves possession of its key.
+func (s *oauthService) exchangedToken(ctx *fasthttp.RequestCtx, ctxVal context.Context, logger ports.Logger, token, tokenType string, cnf *models.Confirmation) (*models.Token, bool) {
+	if token == "" || (tokenType != constants.TokenTypeAccessToken && tokenType != constants.TokenTypeJwt) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "missing token or unsupported token type"})
+		return nil, false
+	}
+
+	tokenInfo, err := s.ingressRepository.Token.ResolveToken(ctxVal, token)
+	if err != nil {
+		if !errors.Is(err, utils.ErrInvalidToken) {
+			logger.Error("Failed to resolve exchanged token", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return nil, false
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "invalid or expired token"})
+		return nil, false
+	}
+
+	if _, err := s.ingressRepository.Session.Validate(ctxVal, tokenInfo); err != nil {
+		if !errors.Is(err, utils.ErrInvalidSession) {
+			logger.Error("Failed to validate exchanged token", zap.Error(err))
+			writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+			return nil, false
+		}
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "token revoked"})
+		return nil, false
+	}
+
+	if bound := tokenInfo.Cnf; bound != nil {
+		proven := true
+		if bound.X5tS256 != "" {
+			cert := s.ingressRepository.Mtls.Certificate(ctx)
+			proven = cert != nil && subtle.ConstantTimeCompare([]byte(utils.CertThumbprint(cert)), []byte(bound.X5tS256)) == 1
+		}
+		if bound.Jkt != "" {
+			proven = proven && cnf != nil && subtle.ConstantTimeCompare([]byte(cnf.Jkt), []byte(bound.Jkt)) == 1
+		}
+		if !proven {
+			writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "possession of the token key was not proven"})
+			return nil, false
+		}
+	}
+
+	return tokenInfo, true
+}
+
+// impersonate issues a time limited token of the user to a staff member, recorded before it is returned
+func (s *oauthService) impersonate(ctx *fasthttp.RequestCtx, ctxVal context.Context, logger ports.Logger, actor *models.Token, requestedSubject string, cnf *models.Confirmation) {
+	var (
+		cfg    = s.config.TokenExchange
+		reason = utils.Sanitize(string(ctx.PostArgs().Peek("reason")))
+	)
+
+	// Only people impersonate, and only with their own token
+	if actor == nil || actor.UserID == 0 || actor.Act != nil || !actor.HasPermission(constants.PrmImpersonate) {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthUnauthorizedClient, ErrorDescription: "the actor may not impersonate users"})
+		return
+	}
+
+	if cfg.RequireReason && reason == "" {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "a reason is required"})
+		return
+	}
+
+	userID, err := strconv.Atoi(requestedSubject)
+	if err != nil || userID == actor.UserID {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "invalid requested_subject"})
+		return
+	}
+
+	user, err := s.egressRepository.User.GetByID(ctxVal, userID)
+	if err == nil && user == nil {
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidRequest, ErrorDescription: "invalid requested_subject"})
+		return
+	}
+	if err != nil || user.Status != constants.StatusActive {
+		logger.Info("user unavailable for impersonation", zap.Int("userID", userID), zap.Error(err))
+		writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidGrant, ErrorDescription: "user is not active"})
+		return
+	}
+
+	granted, err := userPermissions(ctxVal, s.egressRepository.Role, user)
+	if err != nil {
+		logger.Error("Failed to resolve user permissions", zap.Int("userID", user.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	// Ends with the session of the actor at the latest
+	notAfter := time.Now().Add(cfg.ImpersonationLifeSpan)
+	if actor.ExpiresAt != nil && actor.ExpiresAt.Time.Before(notAfter) {
+		notAfter = actor.ExpiresAt.Time
+	}
+
+	token, expiresIn, permissions, ok := s.issueExchanged(ctx, logger, user.Role, user.ID, withoutAdminWrites(granted), &models.TokenOptions{
+		SessionID:    actor.SessionID,
+		NotAfter:     notAfter,
+		Audience:     audiences(ctx.PostArgs()),
+		Confirmation: cnf,
+		Actor:        actingParty(actor, nil),
+	})
+	if !ok {
+		return
+	}
+
+	tokenInfo, err := s.ingressRepository.Token.ResolveToken(ctxVal, token)
+	if err != nil {
+		logger.Error("Failed to read issued token", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	impersonation := &models.Impersonation{
+		ID:          uuid.NewString(),
+		ActorID:     actor.UserID,
+		UserID:      user.ID,
+		TokenID:     tokenInfo.ID,
+		Permissions: permissions,
+		Reason:      reason,
+		IP:          utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies),
+		CreatedAt:   time.Now(),
+		ExpiresAt:   tokenInfo.ExpiresAt.Time,
+	}
+	if err := s.egressRepository.Impersonation.Add(ctxVal, impersonation); err != nil {
+		logger.Error("Failed to record impersonation", zap.Int("actorID", actor.UserID), zap.Int("userID", user.ID), zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return
+	}
+
+	logger.Info("impersonation started", zap.Int("actorID", actor.UserID), zap.Int("userID", user.ID), zap.String("tokenID", tokenInfo.ID))
+	s.writeExchangedToken(ctx, token, expiresIn, permissions, cnf)
+}
+
+// issueExchanged generates the exchanged token, narrowed to the requested scope when one is sent
+func (s *oauthService) issueExchanged(ctx *fasthttp.RequestCtx, logger ports.Logger, role constants.Roles, userID int, permissions []string, opts *models.TokenOptions) (string, time.Duration, []string, bool) {
+	if scope := strings.Fields(string(ctx.PostArgs().Peek("scope"))); len(scope) > 0 {
+		granted := sliceStringToMapStruct(permissions)
+		for _, permission := range scope {
+			if _, found := granted[permission]; !found {
+				writeOAuth(ctx, http.StatusBadRequest, &models.OAuthError{Error: constants.OAuthInvalidScope, ErrorDescription: fmt.Sprintf("%s is not granted to the subject", permission)})
+				return "", 0, nil, false
+			}
+		}
+		permissions = scope
+	}
+
+	token, err := s.ingressRepository.Token.IssueToken(ctx, role, permissions, &models.User{ID: userID}, opts)
+	if err != nil {
+		logger.Error("Token generation failed", zap.Error(err))
+		writeOAuth(ctx, http.StatusInternalServerError, &models.OAuthError{Error: constants.OAuthServerError})
+		return "", 0, nil, false
+	}
+
+	expiresIn := s.config.Jwt.LifeSpan
+	if untilEnd := time.Until(opts.NotAfter); !opts.NotAfter.IsZero() && untilEnd < expiresIn {
+		expiresIn = untilEnd
+	}
+	return token, expiresIn, permissions, true
+}
+
+func (s *oauthService) writeExchangedToken(ctx *fasthttp.RequestCtx, token string, expiresIn time.Duration, permissions []string, cnf *models.Confirmation) {
+	writeOAuth(ctx, http.StatusOK, &models.TokenResponse{
+		AccessToken:     token,
+		TokenType:       tokenType(cnf),
+		ExpiresIn:       int(expiresIn.Seconds()),
+		IssuedTokenType: constants.TokenTypeAccessToken,
+		Scope:           strings.Join(permissions, " "),
+	})
+}
+
+// actingParty describes the holder of the actor token, prior is the actor the subject token already had
+func actingParty(actor *models.Token, prior *models.Actor) *models.Actor {
+	party := &models.Actor{
+		UserID:           actor.UserID,
+		ServiceAccountID: actor.ServiceAccountID,
+		Act:              prior,
+	}
+
+	party.Subject = strconv.Itoa(actor.UserID)
+	if actor.ServiceAccountID != "" {
+		party.Subject = actor.ServiceAccountID
+	}
+	return party
+}
+
+func audiences(args *fasthttp.Args) []string {
+	var audience []string
+	for _, value := range args.PeekMulti("audience") {
+		audience = append(audience, string(value))
+	}
+	return audience
+}
+
+// withoutAdminWrites drops the permissions a token acting on behalf of someone else never carries
+func withoutAdminWrites(permissions []string) []string {
+	denied := sliceStringToMapStruct(constants.AdminWritePermissions)
+
+	allowed := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if _, found := denied[permission]; !found {
+			allowed = append(allowed, permission)
+		}
+	}
+	return allowed
+}
+
+// Impersonations lists the latest impersonations, newest first
+func (s *oauthService) Impersonations(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	impersonations, err := s.egressRepository.Impersonation.List(ctxVal, impersonationLimit)
+	if err != nil {
+		logger.Error("Failed to fetch impersonations", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "IM", 1),
+			Message: "Failed to fetch impersonations",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Impersonations fetched successfully").SetPayload(impersonations).Send(ctx)
+}
@@ -20,18 +20,20 @@ import (
 )
 
 type permissionService struct {
-	errCodePrefix    string
-	config           *models.Config
-	logger           ports.Logger
-	egressRepository egress.Repository
+	errCodePrefix     string
+	config            *models.Config
+	logger            ports.Logger
+	egressRepository  egress.Repository
+	ingressRepository ingress.Repository
 }
 
-func NewPermissionService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.PermissionServicePorts {
+func NewPermissionService(config *models.Config, logger ports.Logger, egressRepository egress.Repository, ingressRepository ingress.Repository) ingress.PermissionServicePorts {
 	return &permissionService{
-		errCodePrefix:    "PN-%s-%d",
-		config:           config,
-		logger:           logger,
-		egressRepository: egressRepository,
+		errCodePrefix:     "PN-%s-%d",
+		config:            config,
+		logger:            logger,
+		egressRepository:  egressRepository,
+		ingressRepository: ingressRepository,
 	}
 }
 
@@ -206,3 +208,40 @@ func (s *permissionService) Delete(ctx *fasthttp.RequestCtx) {
 	response.SetStatus(true).SetStatusCode(http.StatusOK).
 		SetMessage(fmt.Sprintf("Permission '%s' deleted successfully", permissionID)).Send(ctx)
 }
+
+// Index publishes the permission index that token bitmaps refer to, an older version is returned
+// for as long as tokens encoded with it can be valid
+func (s *permissionService) Index(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		version  = utils.Sanitize(string(ctx.QueryArgs().Peek("version")))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	index, err := s.ingressRepository.Token.PermissionIndex(ctxVal, version)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "IX", 1),
+				Message: fmt.Sprintf("Permission index '%s' not found", version),
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to fetch permission index", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "IX", 2),
+			Message: "Failed to fetch permission index",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Permission index fetched successfully").SetPayload(index).Send(ctx)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"go.uber.org/zap"
+)
+
+type riskService struct {
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewRiskService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.RiskServicePorts {
+	return &riskService{
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+// Evaluate scores the signin against the user's successful logins within the history window
+// and maps the score to allow, step up or block.
+func (r *riskService) Evaluate(ctx context.Context, input *models.RiskInput) *models.RiskAssessment {
+	assessment := &models.RiskAssessment{Decision: constants.RiskAllow}
+
+	cfg := r.config.Risk
+	if cfg == nil || !cfg.Enabled {
+		return assessment
+	}
+	weights := cfg.Weights
+
+	if utils.IPInCIDRs(input.IP, cfg.IPDenyList) {
+		assessment.Add(weights.DeniedIP, "ip on deny list")
+	}
+	if utils.IPInCIDRs(input.IP, cfg.IPAllowList) {
+		assessment.Add(-weights.AllowedIP, "ip on allow list")
+	}
+
+	history, err := r.egressRepository.LoginHistory.GetByIDAndLoginAt(ctx, input.User.ID, input.At.Add(-cfg.HistoryWindow))
+	if err != nil {
+		r.logger.Error("Failed to fetch login history for risk", zap.Int("userID", input.User.ID), zap.Error(err))
+	}
+
+	successful := make([]models.LoginHistory, 0, len(history))
+	for _, login := range history {
+		if login.Status == constants.StatusSuccess {
+			successful = append(successful, login)
+		}
+	}
+
+	r.scoreDevice(assessment, input, successful)
+	r.scoreNetwork(assessment, input, successful)
+	r.scoreTimeOfDay(assessment, input, successful)
+
+	if assessment.Score < 0 {
+		assessment.Score = 0
+	}
+
+	switch {
+	case assessment.Score >= cfg.BlockScore:
+		assessment.Decision = constants.RiskBlock
+	case assessment.Score >= cfg.StepUpScore:
+		assessment.Decision = constants.RiskStepUp
+	}
+
+	return assessment
+}
+
+func (r *riskService) scoreDevice(assessment *models.RiskAssessment, input *models.RiskInput, history []models.LoginHistory) {
+	if input.DeviceHash == "" {
+		assessment.Add(r.config.Risk.Weights.MissingDevice, "device not reported")
+		return
+	}
+
+	for _, login := range history {
+		if login.DeviceHash == input.DeviceHash {
+			return
+		}
+	}
+	assessment.Add(r.config.Risk.Weights.NewDevice, "new device")
+}
+
+// scoreNetwork compares with the most recent successful login, history is ordered newest first
+func (r *riskService) scoreNetwork(assessment *models.RiskAssessment, input *models.RiskInput, history []models.LoginHistory) {
+	if len(history) == 0 {
+		return
+	}
+	last := history[0]
+
+	if input.Country != "" && last.Country != "" && input.Country != last.Country {
+		assessment.Add(r.config.Risk.Weights.CountryChange, "country changed from "+last.Country+" to "+input.Country)
+	}
+	if input.Asn != "" && last.Asn != "" && input.Asn != last.Asn {
+		assessment.Add(r.config.Risk.Weights.AsnChange, "network changed from "+last.Asn+" to "+input.Asn)
+	}
+}
+
+// scoreTimeOfDay flags logins in an hour (+/- one) in which the user has never signed in before
+func (r *riskService) scoreTimeOfDay(assessment *models.RiskAssessment, input *models.RiskInput, history []models.LoginHistory) {
+	if len(history) < r.config.Risk.MinHistory {
+		return
+	}
+
+	var hours [24]int
+	for _, login := range history {
+		hours[login.LoginAt.UTC().Hour()]++
+	}
+
+	hour := input.At.UTC().Hour()
+	if hours[(hour+23)%24]+hours[hour]+hours[(hour+1)%24] == 0 {
+		assessment.Add(r.config.Risk.Weights.UnusualHour, "unusual time of day")
+	}
+}
@@ -6,11 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
-	ingressModel "github.com/bhupendra-dudhwal/sso-gateway/internal/core/models/ingress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
@@ -75,7 +76,7 @@ func (s *roleService) Info(ctx *fasthttp.RequestCtx) {
 		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
 	)
 
-	roleID := utils.Sanitize("")
+	roleID := utils.GetPathParam(ctx, "id")
 
 	ctxVal, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
@@ -127,7 +128,7 @@ func (s *roleService) Add(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	role.Sanitize(constants.Create, 0)
+	role.Sanitize(constants.Create, actorOf(ctx))
 	if err := role.Validate(); err != nil {
 		logger.Error("validation failed", zap.Error(err))
 
@@ -139,21 +140,13 @@ func (s *roleService) Add(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	seen := map[string]struct{}{}
-	uniquePermissions := make([]string, 0, len(role.Permissions))
-	for _, p := range role.Permissions {
-		if _, exists := seen[p]; !exists {
-			seen[p] = struct{}{}
-			uniquePermissions = append(uniquePermissions, p)
-		}
-	}
-
-	// Get permissions from databses
-	var permissions []ingressModel.Permission
-
 	ctxVal, cancel := withTimeout(ctx, 1*time.Minute)
 	defer cancel()
-	permissions, err := s.egressRepository.Permission.GetByIDs(ctxVal, uniquePermissions)
+	if !s.checkParents(ctx, ctxVal, response, logger, &role, "AD") {
+		return
+	}
+
+	permissions, err := s.activePermissions(ctxVal, role.Permissions)
 	if err != nil {
 		logger.Error("Failed to fetch permission", zap.Error(err))
 
@@ -165,7 +158,7 @@ func (s *roleService) Add(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	if len(permissions) == 0 {
+	if len(permissions) == 0 && len(role.Permissions) > 0 {
 		logger.Warn("Invalid permissions")
 
 		response.SetError(&models.Error{
@@ -175,20 +168,12 @@ func (s *roleService) Add(ctx *fasthttp.RequestCtx) {
 		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
 		return
 	}
-
-	// Filter valid permissions
-	var validPermissions = make([]string, 0, len(permissions))
-	for _, p := range permissions {
-		if p.Status == constants.StatusActive {
-			validPermissions = append(validPermissions, p.ID)
-		}
-	}
-	role.Permissions = validPermissions
+	role.Permissions = permissions
 
 	// Add role to database
 	ctxVal, cancel = withTimeout(ctx, 1*time.Minute)
 	defer cancel()
-	if err := s.egressRepository.Role.Add(ctx, &role); err != nil {
+	if err := s.egressRepository.Role.Add(ctxVal, &role); err != nil {
 		if errors.Is(err, utils.ErrDuplicate) {
 			logger.Info("Role already exists", zap.Error(err))
 
@@ -207,27 +192,192 @@ func (s *roleService) Add(ctx *fasthttp.RequestCtx) {
 			Message: "Failed to create role",
 			Detail:  err,
 		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
 	}
 
 	// Success
 	response.SetStatus(true).SetMessage("Role created successfully").SetStatusCode(http.StatusOK).SetPayload(&role).Send(ctx)
 }
 
+// Update replaces the description, permissions, status and session limits of the role
 func (s *roleService) Update(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		roleID   = utils.GetPathParam(ctx, "id")
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		role     models.Role
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&role); err != nil {
+		logger.Error("Failed to decode role request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	role.ID = constants.Roles(roleID)
+	role.Sanitize(constants.Update, actorOf(ctx))
+	if err := role.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	stored, err := s.egressRepository.Role.GetByID(ctxVal, role.ID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Role '%s' not found", roleID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "UP", 3),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to fetch role info", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 4),
+			Message: "Failed to fetch role info",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if isSystemRole(stored) && role.Status != constants.StatusActive {
+		logger.Warn("Attempt to deactivate a system role", zap.String("role", roleID))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 5),
+			Message: fmt.Sprintf("System role '%s' cannot be deactivated", roleID),
+			Detail:  nil,
+		}).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	if !s.checkParents(ctx, ctxVal, response, logger, &role, "UP") {
+		return
+	}
+
+	permissions, err := s.activePermissions(ctxVal, role.Permissions)
+	if err != nil {
+		logger.Error("Failed to fetch permission", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 6),
+			Message: "Failed to fetch permission",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if len(permissions) == 0 && len(role.Permissions) > 0 {
+		logger.Info("Invalid permissions")
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 7),
+			Message: "Invalid permissions",
+			Detail:  nil,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+	role.Permissions = permissions
+
+	if err := s.egressRepository.Role.Update(ctxVal, &role); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Role '%s' not found", roleID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "UP", 8),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to update role", zap.Error(err))
 
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UP", 9),
+			Message: "Failed to update role",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	role.IsSystem, role.CreatedBy, role.CreatedAt = stored.IsSystem, stored.CreatedBy, stored.CreatedAt
+
+	// Success
+	response.SetStatus(true).SetMessage(fmt.Sprintf("Role '%s' updated successfully", roleID)).SetStatusCode(http.StatusOK).SetPayload(&role).Send(ctx)
 }
 
 func (s *roleService) Delete(ctx *fasthttp.RequestCtx) {
 	var (
 		reqID    = utils.GetField(ctx, constants.CtxRequestID)
 		logger   = s.logger.With(zap.String("requestID", reqID))
-		roleID   = utils.Sanitize("")
+		roleID   = utils.GetPathParam(ctx, "id")
 		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
 	)
 
 	ctxVal, cancel := withTimeout(ctx, time.Minute)
 	defer cancel()
 
+	hierarchy, err := loadRoleHierarchy(ctxVal, s.egressRepository.Role)
+	if err != nil {
+		logger.Error("Failed to fetch roles", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 5),
+			Message: "Failed to fetch roles",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	// System roles are relied on by the gateway
+	if stored, found := hierarchy[constants.Roles(roleID)]; (found && isSystemRole(stored)) || slices.Contains(constants.SystemRoles, constants.Roles(roleID)) {
+		logger.Warn("Attempt to delete a system role", zap.String("role", roleID))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 3),
+			Message: fmt.Sprintf("System role '%s' cannot be deleted", roleID),
+			Detail:  nil,
+		}).SetStatusCode(http.StatusForbidden).Send(ctx)
+		return
+	}
+
+	// Deleting a parent would silently take permissions away from the roles inheriting it
+	for _, role := range hierarchy {
+		if slices.Contains(role.Parents, roleID) {
+			msg := fmt.Sprintf("Role '%s' is inherited by role '%s'", roleID, role.ID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "DE", 4),
+				Message: msg,
+				Detail:  nil,
+			}).SetStatusCode(http.StatusConflict).Send(ctx)
+			return
+		}
+	}
+
 	if err := s.egressRepository.Role.DeleteByID(ctxVal, constants.Roles(roleID)); err != nil {
 		if errors.Is(err, utils.ErrDocumentNotFound) {
 			msg := fmt.Sprintf("Role '%s' not found", roleID)
@@ -254,3 +404,120 @@ func (s *roleService) Delete(ctx *fasthttp.RequestCtx) {
 	// Success
 	response.SetStatus(true).SetMessage(fmt.Sprintf("Role '%s' deleted successfully", roleID)).SetStatusCode(http.StatusOK).Send(ctx)
 }
+
+// Permissions lists the effective permissions of the role with the roles granting each of them
+func (s *roleService) Permissions(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		roleID   = utils.GetPathParam(ctx, "id")
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	hierarchy, err := loadRoleHierarchy(ctxVal, s.egressRepository.Role)
+	if err != nil {
+		logger.Error("Failed to fetch roles", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "EP", 1),
+			Message: "Failed to fetch roles",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if _, found := hierarchy[constants.Roles(roleID)]; !found {
+		msg := fmt.Sprintf("Role '%s' not found", roleID)
+		logger.Info(msg)
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "EP", 2),
+			Message: msg,
+			Detail:  nil,
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Effective permissions fetched successfully").SetPayload(hierarchy.effective(constants.Roles(roleID))).Send(ctx)
+}
+
+// checkParents verifies that the parents of the role exist and that inheriting from them forms no cycle
+func (s *roleService) checkParents(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, role *models.Role, op string) bool {
+	if len(role.Parents) == 0 {
+		return true
+	}
+
+	hierarchy, err := loadRoleHierarchy(ctxVal, s.egressRepository.Role)
+	if err != nil {
+		logger.Error("Failed to fetch roles", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, op, 10),
+			Message: "Failed to fetch roles",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return false
+	}
+
+	for _, parent := range role.Parents {
+		if _, found := hierarchy[constants.Roles(parent)]; !found && parent != role.ID.String() {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, op, 11),
+				Message: fmt.Sprintf("Parent role '%s' not found", parent),
+			}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+			return false
+		}
+	}
+
+	// The hierarchy as it would be once the role is stored
+	hierarchy[role.ID] = role
+	if cycle := hierarchy.cycle(role.ID); cycle != nil {
+		path := make([]string, 0, len(cycle))
+		for _, id := range cycle {
+			path = append(path, id.String())
+		}
+		msg := fmt.Sprintf("Role inheritance forms a cycle: %s", strings.Join(path, " -> "))
+		logger.Info(msg)
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, op, 12),
+			Message: msg,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return false
+	}
+
+	return true
+}
+
+// activePermissions returns the active permissions among the requested ones, without duplicates
+func (s *roleService) activePermissions(ctx context.Context, requested []string) ([]string, error) {
+	seen := map[string]struct{}{}
+	uniquePermissions := make([]string, 0, len(requested))
+	for _, p := range requested {
+		if _, exists := seen[p]; !exists {
+			seen[p] = struct{}{}
+			uniquePermissions = append(uniquePermissions, p)
+		}
+	}
+
+	permissions, err := s.egressRepository.Permission.GetByIDs(ctx, uniquePermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var validPermissions = make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if p.Status == constants.StatusActive {
+			validPermissions = append(validPermissions, p.ID)
+		}
+	}
+	return validPermissions, nil
+}
+
+// isSystemRole also checks the built-in IDs, rows stored before the is_system column default to false
+func isSystemRole(role *models.Role) bool {
+	return role.IsSystem || slices.Contains(constants.SystemRoles, role.ID)
+}
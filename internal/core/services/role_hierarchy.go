@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+)
+
+// roleHierarchy indexes the roles by ID to walk their inheritance
+type roleHierarchy map[constants.Roles]*models.Role
+
+func loadRoleHierarchy(ctx context.Context, repository egress.RoleRepositoryPorts) (roleHierarchy, error) {
+	roles, err := repository.GetRolesWithoutPagination(ctx)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	hierarchy := make(roleHierarchy, len(roles))
+	for i := range roles {
+		hierarchy[roles[i].ID] = &roles[i]
+	}
+	return hierarchy, nil
+}
+
+// inheritedPermissions returns the permissions of the role and of its ancestors, the hierarchy is
+// only loaded for roles with parents
+func inheritedPermissions(ctx context.Context, repository egress.RoleRepositoryPorts, role *models.Role) ([]string, error) {
+	if len(role.Parents) == 0 {
+		return role.Permissions, nil
+	}
+
+	hierarchy, err := loadRoleHierarchy(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	// The role is walked as given, it may not be stored yet
+	hierarchy[role.ID] = role
+
+	effective := hierarchy.effective(role.ID)
+	permissions := make([]string, 0, len(effective.Permissions))
+	for _, permission := range effective.Permissions {
+		permissions = append(permissions, permission.ID)
+	}
+	return permissions, nil
+}
+
+// userPermissions returns the permissions of the user together with the effective permissions of
+// their role, an unknown or inactive role grants nothing
+func userPermissions(ctx context.Context, repository egress.RoleRepositoryPorts, user *models.User) ([]string, error) {
+	role, err := repository.GetByID(ctx, user.Role)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return nil, err
+	}
+	if role == nil || role.Status != constants.StatusActive {
+		return user.Permissions, nil
+	}
+
+	inherited, err := inheritedPermissions(ctx, repository, role)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := slices.Clone(user.Permissions)
+	for _, permission := range inherited {
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
+
+// cycle returns the roles of a cycle reachable through the parents of the role, starting and
+// ending with the same role, none when the hierarchy has no cycle there
+func (h roleHierarchy) cycle(id constants.Roles) []constants.Roles {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	var (
+		state = make(map[constants.Roles]int)
+		path  []constants.Roles
+		visit func(constants.Roles) []constants.Roles
+	)
+
+	visit = func(id constants.Roles) []constants.Roles {
+		switch state[id] {
+		case visiting:
+			for i, role := range path {
+				if role == id {
+					return append(append([]constants.Roles{}, path[i:]...), id)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		role, found := h[id]
+		if !found {
+			return nil
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, parent := range role.Parents {
+			if cycle := visit(constants.Roles(parent)); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+
+	return visit(id)
+}
+
+// effective walks the ancestors of the role breadth first, nearest first. An inactive ancestor
+// grants nothing, neither its permissions nor those it inherits. Cycles are walked once.
+func (h roleHierarchy) effective(id constants.Roles) *models.EffectivePermissions {
+	result := &models.EffectivePermissions{
+		Role:        id,
+		Ancestors:   []constants.Roles{},
+		Permissions: []models.EffectivePermission{},
+	}
+
+	var (
+		index = make(map[string]int)
+		seen  = map[constants.Roles]struct{}{id: {}}
+		queue = []constants.Roles{id}
+	)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		role, found := h[current]
+		if !found || (current != id && role.Status != constants.StatusActive) {
+			continue
+		}
+
+		if current != id {
+			result.Ancestors = append(result.Ancestors, current)
+		}
+
+		for _, permission := range role.Permissions {
+			i, found := index[permission]
+			if !found {
+				i = len(result.Permissions)
+				index[permission] = i
+				result.Permissions = append(result.Permissions, models.EffectivePermission{ID: permission})
+			}
+			result.Permissions[i].Sources = append(result.Permissions[i].Sources, current)
+		}
+
+		for _, parent := range role.Parents {
+			if _, found := seen[constants.Roles(parent)]; !found {
+				seen[constants.Roles(parent)] = struct{}{}
+				queue = append(queue, constants.Roles(parent))
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,731 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// auditLimit caps the audit trail returned by the API
+const auditLimit = 200
+
+type serviceAccountService struct {
+	errCodePrefix     string
+	config            *models.Config
+	logger            ports.Logger
+	egressRepository  egress.Repository
+	ingressRepository ingress.Repository
+}
+
+func NewServiceAccountService(config *models.Config, logger ports.Logger, egressRepository egress.Repository, ingressRepository ingress.Repository) ingress.ServiceAccountServicePorts {
+	return &serviceAccountService{
+		errCodePrefix:     "SA-%s-%d",
+		config:            config,
+		logger:            logger,
+		egressRepository:  egressRepository,
+		ingressRepository: ingressRepository,
+	}
+}
+
+func (s *serviceAccountService) Enabled() bool {
+	return s.config.ServiceAccount != nil && s.config.ServiceAccount.Enabled && s.egressRepository.ServiceAccount != nil
+}
+
+// Authenticate verifies an RFC 7523 assertion: a JWT signed by one of the keys of the service
+// account, with the account id as iss and sub, the configured audience and a single use jti
+func (s *serviceAccountService) Authenticate(ctx context.Context, assertion, clientIP string) (*models.ServiceAccount, error) {
+	if !s.Enabled() {
+		return nil, utils.ErrInvalidAssertion
+	}
+
+	var (
+		cnf       = s.config.ServiceAccount
+		claims    jwt.RegisteredClaims
+		key       *models.ServiceAccountKey
+		lookupErr error
+	)
+
+	// Claims are decoded before the key function runs, so the subject picks the key
+	_, err := jwt.ParseWithClaims(assertion, &claims, func(t *jwt.Token) (interface{}, error) {
+		keyID, _ := t.Header["kid"].(string)
+		if claims.Subject == "" || keyID == "" {
+			return nil, errors.New("assertion without sub or kid")
+		}
+
+		found, err := s.egressRepository.ServiceAccount.GetKey(ctx, claims.Subject, keyID)
+		if err != nil {
+			if !errors.Is(err, utils.ErrDocumentNotFound) {
+				lookupErr = err
+			}
+			return nil, err
+		}
+
+		if found.RevokedAt != nil || (found.ExpiresAt != nil && time.Now().After(*found.ExpiresAt)) {
+			return nil, errors.New("key revoked or expired")
+		}
+
+		if t.Method.Alg() != found.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Method.Alg())
+		}
+
+		publicKey, _, err := parsePublicKey(found.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		key = found
+		return publicKey, nil
+	})
+	if lookupErr != nil {
+		return nil, fmt.Errorf("failed to fetch service account key: %w", lookupErr)
+	}
+	if err != nil {
+		if claims.Subject != "" {
+			s.audit(claims.Subject, constants.SaActionTokenDenied, 0, "", clientIP, err.Error())
+		}
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidAssertion, err)
+	}
+
+	deny := func(reason string) (*models.ServiceAccount, error) {
+		s.audit(claims.Subject, constants.SaActionTokenDenied, 0, key.ID, clientIP, reason)
+		return nil, fmt.Errorf("%w: %s", utils.ErrInvalidAssertion, reason)
+	}
+
+	if claims.Issuer != claims.Subject {
+		return deny("iss must equal sub")
+	}
+
+	if !claims.VerifyAudience(cnf.Audience, true) {
+		return deny("unexpected audience")
+	}
+
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil || claims.ID == "" {
+		return deny("exp, iat and jti are required")
+	}
+
+	if claims.ExpiresAt.Sub(claims.IssuedAt.Time) > cnf.MaxAssertionAge {
+		return deny("assertion lifetime too long")
+	}
+
+	fresh, err := s.egressRepository.Replay.Consume(ctx, "assertion", claims.Subject+":"+claims.ID, time.Until(claims.ExpiresAt.Time)+time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return deny("assertion replayed")
+	}
+
+	account, err := s.egressRepository.ServiceAccount.GetByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidAssertion
+		}
+		return nil, err
+	}
+
+	if account.Status != constants.StatusActive {
+		return deny(fmt.Sprintf("service account is %s", account.Status))
+	}
+
+	go func(keyID string) {
+		if err := s.egressRepository.ServiceAccount.TouchKey(context.Background(), keyID, time.Now()); err != nil {
+			s.logger.Error("Failed to update service account key usage", zap.String("kid", keyID), zap.Error(err))
+		}
+	}(key.ID)
+
+	return account, nil
+}
+
+// IssueToken issues an access token with the effective permissions of every active role of the account
+func (s *serviceAccountService) IssueToken(ctx context.Context, account *models.ServiceAccount, clientIP string, cnf *models.Confirmation) (string, time.Duration, error) {
+	roleIDs := make([]constants.Roles, 0, len(account.Roles))
+	for _, role := range account.Roles {
+		roleIDs = append(roleIDs, constants.Roles(role))
+	}
+
+	roles, err := s.egressRepository.Role.GetByIDs(ctx, roleIDs)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return "", 0, fmt.Errorf("failed to fetch roles: %w", err)
+	}
+
+	var (
+		primary     constants.Roles
+		seen        = make(map[string]struct{})
+		permissions []string
+	)
+	for _, role := range roles {
+		if role.Status != constants.StatusActive {
+			continue
+		}
+		if primary == "" {
+			primary = role.ID
+		}
+
+		rolePermissions, err := inheritedPermissions(ctx, s.egressRepository.Role, &role)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to resolve role permissions: %w", err)
+		}
+		for _, permission := range rolePermissions {
+			if _, found := seen[permission]; !found {
+				seen[permission] = struct{}{}
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	lifeSpan := s.config.ServiceAccount.TokenLifeSpan
+	if s.config.Jwt.LifeSpan < lifeSpan {
+		lifeSpan = s.config.Jwt.LifeSpan
+	}
+
+	token, err := s.ingressRepository.Token.IssueToken(ctx, primary, permissions, nil, &models.TokenOptions{
+		NotAfter:         time.Now().Add(lifeSpan),
+		ServiceAccountID: account.ID,
+		Confirmation:     cnf,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	s.audit(account.ID, constants.SaActionTokenIssued, 0, "", clientIP, "")
+	return token, lifeSpan, nil
+}
+
+// audit records an entry of the audit trail in the background
+func (s *serviceAccountService) audit(accountID string, action constants.ServiceAccountAction, actorID int, keyID, ip, detail string) {
+	entry := &models.ServiceAccountAudit{
+		ServiceAccountID: accountID,
+		Action:           action,
+		ActorID:          actorID,
+		KeyID:            keyID,
+		IP:               ip,
+		Detail:           detail,
+		CreatedAt:        time.Now(),
+	}
+
+	go func() {
+		if err := s.egressRepository.ServiceAccount.AddAudit(context.Background(), entry); err != nil {
+			s.logger.Error("Failed to record service account audit", zap.String("serviceAccountID", accountID), zap.String("action", action.String()), zap.Error(err))
+		}
+	}()
+}
+
+// revokeTokens rejects every token issued to the account so far
+func (s *serviceAccountService) revokeTokens(ctx context.Context, accountID string) error {
+	key := fmt.Sprintf(constants.CacheKeySaCutoff, accountID)
+	return s.egressRepository.Cache.Add(ctx, key, time.Now().Unix(), s.config.ServiceAccount.TokenLifeSpan+time.Minute, constants.CacheUpdate)
+}
+
+// validRoles reports the first role that does not exist
+func (s *serviceAccountService) validRoles(ctx context.Context, roles []string) (string, error) {
+	for _, role := range roles {
+		if _, err := s.egressRepository.Role.GetByID(ctx, constants.Roles(role)); err != nil {
+			if errors.Is(err, utils.ErrDocumentNotFound) {
+				return role, nil
+			}
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// parsePublicKey parses a PEM public key and returns the only signing algorithm accepted for it
+func parsePublicKey(pemKey string) (interface{}, string, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, "", errors.New("public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < 2048 {
+			return nil, "", errors.New("RSA keys must have at least 2048 bits")
+		}
+		return publicKey, jwt.SigningMethodRS256.Alg(), nil
+	case *ecdsa.PublicKey:
+		if publicKey.Curve != elliptic.P256() {
+			return nil, "", errors.New("only P-256 EC keys are supported")
+		}
+		return publicKey, jwt.SigningMethodES256.Alg(), nil
+	case ed25519.PublicKey:
+		return publicKey, jwt.SigningMethodEdDSA.Alg(), nil
+	default:
+		return nil, "", errors.New("unsupported public key type")
+	}
+}
+
+func (s *serviceAccountService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	accounts, err := s.egressRepository.ServiceAccount.List(ctxVal)
+	if err != nil {
+		logger.Error("Failed to fetch service accounts", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 1),
+			Message: "Failed to fetch service accounts",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	msg := "Service accounts fetched successfully"
+	if len(accounts) == 0 {
+		msg = "No service accounts found"
+		accounts = nil
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(msg).SetPayload(accounts).Send(ctx)
+}
+
+// Info returns the service account along with its keys
+func (s *serviceAccountService) Info(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	account, ok := s.fetch(ctx, ctxVal, response, logger, accountID, "IO")
+	if !ok {
+		return
+	}
+
+	keys, err := s.egressRepository.ServiceAccount.GetKeys(ctxVal, account.ID)
+	if err != nil {
+		logger.Error("Failed to fetch service account keys", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "IO", 3),
+			Message: "Failed to fetch service account keys",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Service account found").SetPayload(map[string]any{
+		"service_account": account,
+		"keys":            keys,
+	}).Send(ctx)
+}
+
+func (s *serviceAccountService) Add(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID    = utils.GetField(ctx, constants.CtxRequestID)
+		logger   = s.logger.With(zap.String("requestID", reqID))
+		response = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		actorID  = actorOf(ctx)
+		payload  models.ServiceAccountRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode service account request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if !s.checkRoles(ctx, ctxVal, response, logger, payload.Roles, "AD") {
+		return
+	}
+
+	now := time.Now()
+	account := &models.ServiceAccount{
+		ID:          uuid.NewString(),
+		Name:        payload.Name,
+		Description: payload.Description,
+		Team:        payload.Team,
+		Roles:       payload.Roles,
+		Status:      constants.StatusActive,
+		CreatedBy:   actorID,
+		UpdatedBy:   actorID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.egressRepository.ServiceAccount.Add(ctxVal, account); err != nil {
+		logger.Error("Failed to create service account", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AD", 5),
+			Message: "Failed to create service account",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	s.audit(account.ID, constants.SaActionCreated, actorID, "", utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), "")
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Service account created successfully").SetPayload(account).Send(ctx)
+}
+
+func (s *serviceAccountService) UpdateRoles(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		actorID   = actorOf(ctx)
+		payload   models.ServiceAccountRolesRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode roles request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UR", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	payload.Sanitize()
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UR", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if !s.checkRoles(ctx, ctxVal, response, logger, payload.Roles, "UR") {
+		return
+	}
+
+	if err := s.egressRepository.ServiceAccount.UpdateRoles(ctxVal, accountID, payload.Roles, actorID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "UR", 5),
+				Message: fmt.Sprintf("Service account '%s' not found", accountID),
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to update service account roles", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "UR", 6),
+			Message: "Failed to update service account roles",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	s.audit(accountID, constants.SaActionRolesUpdated, actorID, "", utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), fmt.Sprintf("%v", payload.Roles))
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Service account roles updated successfully").Send(ctx)
+}
+
+// Disable deactivates the account, revokes all its keys and rejects the tokens already issued
+func (s *serviceAccountService) Disable(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		actorID   = actorOf(ctx)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.ServiceAccount.UpdateStatus(ctxVal, accountID, constants.StatusInactive, actorID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "DE", 1),
+				Message: fmt.Sprintf("Service account '%s' not found", accountID),
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to disable service account", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 2),
+			Message: "Failed to disable service account",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if err := s.egressRepository.ServiceAccount.RevokeKeys(ctxVal, accountID, "", time.Now()); err != nil {
+		logger.Error("Failed to revoke service account keys", zap.Error(err))
+	}
+
+	if err := s.revokeTokens(ctxVal, accountID); err != nil {
+		logger.Error("Failed to revoke service account tokens", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "DE", 3),
+			Message: "Service account disabled but its tokens could not be revoked",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	s.audit(accountID, constants.SaActionDisabled, actorID, "", utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), "")
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Service account '%s' disabled successfully", accountID)).Send(ctx)
+}
+
+// AddKey registers a public key, its id is the kid assertions must carry
+func (s *serviceAccountService) AddKey(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		actorID   = actorOf(ctx)
+		payload   models.ServiceAccountKeyRequest
+	)
+
+	if err := json.NewDecoder(ctx.RequestBodyStream()).Decode(&payload); err != nil {
+		logger.Error("Failed to decode key request", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AK", 1),
+			Message: "Invalid request format",
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	if err := payload.Validate(); err != nil {
+		logger.Info("validation failed", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AK", 2),
+			Message: err.Error(),
+			Detail:  err,
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	_, algorithm, err := parsePublicKey(payload.PublicKey)
+	if err != nil {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AK", 3),
+			Message: err.Error(),
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	account, ok := s.fetch(ctx, ctxVal, response, logger, accountID, "AK")
+	if !ok {
+		return
+	}
+
+	if account.Status != constants.StatusActive {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AK", 4),
+			Message: fmt.Sprintf("Service account is %s", account.Status),
+		}).SetStatusCode(http.StatusConflict).Send(ctx)
+		return
+	}
+
+	key := &models.ServiceAccountKey{
+		ID:               uuid.NewString(),
+		ServiceAccountID: account.ID,
+		PublicKey:        payload.PublicKey,
+		Algorithm:        algorithm,
+		CreatedAt:        time.Now(),
+		ExpiresAt:        payload.ExpiresAt,
+	}
+
+	if err := s.egressRepository.ServiceAccount.AddKey(ctxVal, key); err != nil {
+		logger.Error("Failed to add service account key", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AK", 5),
+			Message: "Failed to add key",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	s.audit(account.ID, constants.SaActionKeyAdded, actorID, key.ID, utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), "")
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Key added successfully").SetPayload(key).Send(ctx)
+}
+
+// RevokeKey revokes the key and the tokens already issued to the account, since they cannot be
+// told apart by key
+func (s *serviceAccountService) RevokeKey(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		keyID     = utils.GetPathParam(ctx, "kid")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		actorID   = actorOf(ctx)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.ServiceAccount.RevokeKeys(ctxVal, accountID, keyID, time.Now()); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "RK", 1),
+				Message: fmt.Sprintf("Key '%s' not found", keyID),
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to revoke service account key", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RK", 2),
+			Message: "Failed to revoke key",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	if err := s.revokeTokens(ctxVal, accountID); err != nil {
+		logger.Error("Failed to revoke service account tokens", zap.Error(err))
+	}
+
+	s.audit(accountID, constants.SaActionKeyRevoked, actorID, keyID, utils.ClientIP(ctx, s.config.App.Server.TrustProxy, s.config.App.Server.TrustedProxies), "")
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage(fmt.Sprintf("Key '%s' revoked successfully", keyID)).Send(ctx)
+}
+
+func (s *serviceAccountService) Audit(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		accountID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+	)
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	entries, err := s.egressRepository.ServiceAccount.GetAudit(ctxVal, accountID, auditLimit)
+	if err != nil {
+		logger.Error("Failed to fetch service account audit", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "AU", 1),
+			Message: "Failed to fetch audit trail",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Audit trail fetched successfully").SetPayload(entries).Send(ctx)
+}
+
+func (s *serviceAccountService) fetch(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, accountID, op string) (*models.ServiceAccount, bool) {
+	account, err := s.egressRepository.ServiceAccount.GetByID(ctxVal, accountID)
+	if err == nil {
+		return account, true
+	}
+
+	if errors.Is(err, utils.ErrDocumentNotFound) {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, op, 11),
+			Message: fmt.Sprintf("Service account '%s' not found", accountID),
+			Detail:  err,
+		}).SetStatusCode(http.StatusNotFound).Send(ctx)
+		return nil, false
+	}
+
+	logger.Error("Failed to fetch service account", zap.Error(err))
+
+	response.SetError(&models.Error{
+		Code:    fmt.Sprintf(s.errCodePrefix, op, 12),
+		Message: "Failed to fetch service account",
+		Detail:  err,
+	}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+	return nil, false
+}
+
+func (s *serviceAccountService) checkRoles(ctx *fasthttp.RequestCtx, ctxVal context.Context, response ports.Response, logger ports.Logger, roles []string, op string) bool {
+	unknown, err := s.validRoles(ctxVal, roles)
+	if err != nil {
+		logger.Error("Failed to fetch roles", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, op, 3),
+			Message: "Failed to fetch roles",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return false
+	}
+
+	if unknown != "" {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, op, 4),
+			Message: fmt.Sprintf("Role '%s' not found", unknown),
+		}).SetStatusCode(http.StatusBadRequest).Send(ctx)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,572 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+type sessionService struct {
+	errCodePrefix    string
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+}
+
+func NewSessionService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.SessionServicePorts {
+	return &sessionService{
+		errCodePrefix:    "SS-%s-%d",
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+	}
+}
+
+func (s *sessionService) Enabled() bool {
+	return s.config.Session != nil && s.config.Session.Enabled && s.egressRepository.Session != nil
+}
+
+// Create records a session for a successful signin and returns its refresh token
+func (s *sessionService) Create(ctx context.Context, user *models.User, attempt *models.LoginHistory, amr []string) (*models.Session, string, error) {
+	id := uuid.NewString()
+	refreshToken, refreshHash, err := newRefreshToken(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limits := s.limits(ctx, user.Role)
+	if err := s.makeRoom(ctx, user.ID, limits); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:          id,
+		UserID:      user.ID,
+		DeviceHash:  attempt.DeviceHash,
+		RefreshHash: refreshHash,
+		IP:          attempt.IP,
+		UserAgent:   attempt.UserAgent,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		IdleTimeout: limits.idleTimeout,
+		Acr:         acrFor(amr),
+		Amr:         amr,
+		AuthTime:    now,
+		ExpiresAt:   now.Add(limits.absoluteLifetime),
+	}
+
+	if err := s.egressRepository.Session.Add(ctx, session); err != nil {
+		return nil, "", fmt.Errorf("failed to add session: %w", err)
+	}
+
+	return session, refreshToken, nil
+}
+
+// StepUp records a fresh authentication on a live session
+func (s *sessionService) StepUp(ctx context.Context, sessionID string, userID int, amr []string) (*models.Session, error) {
+	session, err := s.egressRepository.Session.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidSession
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if session.UserID != userID || s.expired(ctx, session, now) {
+		return nil, utils.ErrInvalidSession
+	}
+
+	acr := acrFor(amr)
+	if err := s.egressRepository.Session.SetAuthentication(ctx, session.ID, acr, amr, now); err != nil {
+		return nil, fmt.Errorf("failed to record authentication: %w", err)
+	}
+
+	session.Acr = acr
+	session.Amr = amr
+	session.AuthTime = now
+	return session, nil
+}
+
+// Refresh rotates the refresh token of a live session. Presenting an already rotated
+// token means it leaked, so the whole session is revoked.
+func (s *sessionService) Refresh(ctx context.Context, refreshToken string) (*models.Session, string, error) {
+	sessionID, secret, found := strings.Cut(refreshToken, ".")
+	if !found || sessionID == "" || secret == "" {
+		return nil, "", utils.ErrInvalidSession
+	}
+
+	session, err := s.egressRepository.Session.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, "", utils.ErrInvalidSession
+		}
+		return nil, "", err
+	}
+
+	if s.expired(ctx, session, time.Now()) {
+		return nil, "", utils.ErrInvalidSession
+	}
+
+	currentHash := utils.HashToken(sessionID, secret)
+	if subtle.ConstantTimeCompare([]byte(session.RefreshHash), []byte(currentHash)) != 1 {
+		s.revokeReused(ctx, session)
+		return nil, "", utils.ErrInvalidSession
+	}
+
+	newToken, newHash, err := newRefreshToken(session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A concurrent refresh with the same token rotated it first, that is a reuse as well
+	if err := s.egressRepository.Session.RotateRefreshHash(ctx, session.ID, currentHash, newHash); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			s.revokeReused(ctx, session)
+			return nil, "", utils.ErrInvalidSession
+		}
+		return nil, "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	// A refresh is activity, it keeps the idle window open
+	now := time.Now()
+	if err := s.egressRepository.Session.Touch(ctx, session.ID, now); err != nil {
+		s.logger.Error("Failed to update session activity", zap.String("sessionID", session.ID), zap.Error(err))
+	}
+
+	session.RefreshHash = newHash
+	session.LastSeenAt = now
+	return session, newToken, nil
+}
+
+func (s *sessionService) revokeReused(ctx context.Context, session *models.Session) {
+	s.logger.Warn("refresh token reuse, revoking session", zap.String("sessionID", session.ID), zap.Int("userID", session.UserID))
+	if err := s.egressRepository.Session.Delete(ctx, session.ID, session.UserID); err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		s.logger.Error("Failed to revoke session", zap.String("sessionID", session.ID), zap.Error(err))
+	}
+}
+
+// Get returns the session when it is still live
+func (s *sessionService) Get(ctx context.Context, id string) (*models.Session, error) {
+	if !s.Enabled() {
+		return nil, utils.ErrInvalidSession
+	}
+
+	session, err := s.egressRepository.Session.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidSession
+		}
+		return nil, err
+	}
+
+	if s.expired(ctx, session, time.Now()) {
+		return nil, utils.ErrInvalidSession
+	}
+
+	return session, nil
+}
+
+// Validate rejects denied tokens and tokens issued before the user signed out everywhere, then
+// checks that the session referenced by the token is still live and records activity
+func (s *sessionService) Validate(ctx context.Context, tokenInfo *models.Token) (*models.Session, error) {
+	if tokenInfo.ID != "" {
+		_, err := s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyRevokedToken, tokenInfo.ID), nil)
+		if err == nil {
+			return nil, utils.ErrInvalidSession
+		}
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, err
+		}
+	}
+
+	if tokenInfo.ServiceAccountID != "" {
+		var cutoff int64
+		if _, err := s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeySaCutoff, tokenInfo.ServiceAccountID), &cutoff); err != nil {
+			if !errors.Is(err, utils.ErrInvalidCacheKey) {
+				return nil, err
+			}
+		} else if tokenInfo.IssuedAt == nil || tokenInfo.IssuedAt.Unix() <= cutoff {
+			return nil, utils.ErrInvalidSession
+		}
+	}
+
+	if tokenInfo.UserID == 0 {
+		return nil, nil
+	}
+
+	var cutoff int64
+	if _, err := s.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyTokenCutoff, tokenInfo.UserID), &cutoff); err != nil {
+		if !errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, err
+		}
+	} else if tokenInfo.IssuedAt == nil || tokenInfo.IssuedAt.Unix() <= cutoff {
+		return nil, utils.ErrInvalidSession
+	}
+
+	if tokenInfo.SessionID == "" || !s.Enabled() {
+		return nil, nil
+	}
+
+	session, err := s.egressRepository.Session.GetByID(ctx, tokenInfo.SessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			return nil, utils.ErrInvalidSession
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !sessionHolder(session, tokenInfo) || s.expired(ctx, session, now) {
+		return nil, utils.ErrInvalidSession
+	}
+
+	if now.Sub(session.LastSeenAt) >= s.config.Session.TouchInterval {
+		session.LastSeenAt = now
+		go func(id string) {
+			if err := s.egressRepository.Session.Touch(context.Background(), id, now); err != nil {
+				s.logger.Error("Failed to update session activity", zap.String("sessionID", id), zap.Error(err))
+			}
+		}(session.ID)
+	}
+
+	return session, nil
+}
+
+// End signs out the session of the token and denies the token itself until it expires
+func (s *sessionService) End(ctx context.Context, tokenInfo *models.Token) error {
+	if tokenInfo.ID != "" && tokenInfo.ExpiresAt != nil {
+		if ttl := time.Until(tokenInfo.ExpiresAt.Time); ttl > 0 {
+			if err := s.egressRepository.Cache.Add(ctx, fmt.Sprintf(constants.CacheKeyRevokedToken, tokenInfo.ID), true, ttl, constants.CacheUpdate); err != nil {
+				return fmt.Errorf("failed to deny token: %w", err)
+			}
+		}
+	}
+
+	if tokenInfo.SessionID == "" || !s.Enabled() {
+		return nil
+	}
+
+	if err := s.egressRepository.Session.Delete(ctx, tokenInfo.SessionID, tokenInfo.UserID); err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// EndAll rejects every token issued to the user so far, revokes their personal access tokens and
+// deletes all their sessions, which also invalidates their refresh tokens
+func (s *sessionService) EndAll(ctx context.Context, userID int) error {
+	// Tokens outlive the cutoff by at most their own life span
+	key := fmt.Sprintf(constants.CacheKeyTokenCutoff, userID)
+	if err := s.egressRepository.Cache.Add(ctx, key, time.Now().Unix(), s.config.Jwt.LifeSpan+time.Minute, constants.CacheUpdate); err != nil {
+		return fmt.Errorf("failed to set token cutoff: %w", err)
+	}
+
+	// Personal access tokens are not tied to a session and outlive any cutoff
+	if s.egressRepository.AccessToken != nil {
+		if err := s.egressRepository.AccessToken.RevokeByUserID(ctx, userID, time.Now()); err != nil {
+			return fmt.Errorf("failed to revoke access tokens: %w", err)
+		}
+	}
+
+	if !s.Enabled() {
+		return nil
+	}
+
+	if err := s.egressRepository.Session.DeleteByUserID(ctx, userID, ""); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return nil
+}
+
+// EnforceLimit signs out the session when the user has more sessions than their role allows
+// and it is not among the newest ones, which happens when the limit is lowered
+func (s *sessionService) EnforceLimit(ctx context.Context, user *models.User, session *models.Session) error {
+	limits := s.limits(ctx, user.Role)
+	if limits.maxSessions <= 0 {
+		return nil
+	}
+
+	sessions, err := s.live(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(sessions) <= limits.maxSessions {
+		return nil
+	}
+
+	// Newest first
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	for _, kept := range sessions[:limits.maxSessions] {
+		if kept.ID == session.ID {
+			return nil
+		}
+	}
+
+	if err := s.egressRepository.Session.Delete(ctx, session.ID, session.UserID); err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return fmt.Errorf("failed to delete session over the limit: %w", err)
+	}
+	return utils.ErrSessionLimit
+}
+
+// makeRoom frees a slot for a new session of the user according to the limit policy
+func (s *sessionService) makeRoom(ctx context.Context, userID int, limits sessionLimits) error {
+	if limits.maxSessions <= 0 {
+		return nil
+	}
+
+	sessions, err := s.live(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	excess := len(sessions) - limits.maxSessions + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if limits.policy == constants.SessionLimitReject {
+		return utils.ErrSessionLimit
+	}
+
+	// Oldest first
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	for _, session := range sessions[:excess] {
+		if err := s.egressRepository.Session.Delete(ctx, session.ID, userID); err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+			return fmt.Errorf("failed to evict session: %w", err)
+		}
+		s.logger.Info("session evicted by concurrent session limit", zap.Int("userID", userID), zap.String("sessionID", session.ID))
+	}
+
+	return nil
+}
+
+// live returns the sessions of the user that have neither expired nor idled out
+func (s *sessionService) live(ctx context.Context, userID int) ([]models.Session, error) {
+	sessions, err := s.egressRepository.Session.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+
+	now := time.Now()
+	live := sessions[:0]
+	for i := range sessions {
+		if !s.expired(ctx, &sessions[i], now) {
+			live = append(live, sessions[i])
+		}
+	}
+
+	return live, nil
+}
+
+type sessionLimits struct {
+	idleTimeout      time.Duration
+	absoluteLifetime time.Duration
+	maxSessions      int
+	policy           constants.SessionLimitPolicy
+}
+
+// limits returns the session limits of the role, falling back to the configured defaults
+func (s *sessionService) limits(ctx context.Context, roleID constants.Roles) sessionLimits {
+	cnf := s.config.Session
+	limits := sessionLimits{
+		idleTimeout:      cnf.IdleTimeout,
+		absoluteLifetime: cnf.AbsoluteLifetime,
+		maxSessions:      cnf.MaxSessions,
+		policy:           cnf.LimitPolicy,
+	}
+
+	role, err := s.egressRepository.Role.GetByID(ctx, roleID)
+	if err != nil {
+		s.logger.Error("Failed to fetch role session limits, using defaults", zap.String("role", roleID.String()), zap.Error(err))
+		return limits
+	}
+
+	if role.IdleTimeout > 0 {
+		limits.idleTimeout = role.IdleTimeout
+	}
+	if role.AbsoluteLifetime > 0 {
+		limits.absoluteLifetime = role.AbsoluteLifetime
+	}
+	if role.MaxSessions > 0 {
+		limits.maxSessions = role.MaxSessions
+	}
+	if role.SessionPolicy != "" {
+		limits.policy = role.SessionPolicy
+	}
+
+	return limits
+}
+
+// expired reports whether the session passed its absolute lifetime or idled out. Idled out
+// sessions are deleted so they no longer show up in the session list.
+func (s *sessionService) expired(ctx context.Context, session *models.Session, now time.Time) bool {
+	if now.After(session.ExpiresAt) {
+		return true
+	}
+
+	if session.IdleTimeout <= 0 || now.Sub(session.LastSeenAt) <= session.IdleTimeout {
+		return false
+	}
+
+	if err := s.egressRepository.Session.Delete(ctx, session.ID, session.UserID); err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		s.logger.Error("Failed to delete idle session", zap.String("sessionID", session.ID), zap.Error(err))
+	}
+	return true
+}
+
+// sessionHolder reports whether the token rides the session, an impersonation token is bound to the
+// session of the staff member acting as the user
+func sessionHolder(session *models.Session, tokenInfo *models.Token) bool {
+	if session.UserID == tokenInfo.UserID {
+		return true
+	}
+	return tokenInfo.Act != nil && tokenInfo.Act.UserID != 0 && session.UserID == tokenInfo.Act.UserID
+}
+
+// newRefreshToken returns a refresh token for the session along with the hash to store
+func newRefreshToken(sessionID string) (string, string, error) {
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionID + "." + secret, utils.HashToken(sessionID, secret), nil
+}
+
+func (s *sessionService) List(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	sessions, err := s.live(ctxVal, tokenInfo.UserID)
+	if err != nil {
+		logger.Error("Failed to fetch sessions", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "LT", 2),
+			Message: "Failed to fetch sessions",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == tokenInfo.SessionID
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Sessions fetched successfully").SetPayload(sessions).Send(ctx)
+}
+
+func (s *sessionService) Revoke(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		sessionID = utils.GetPathParam(ctx, "id")
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RV", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Session.Delete(ctxVal, sessionID, tokenInfo.UserID); err != nil {
+		if errors.Is(err, utils.ErrDocumentNotFound) {
+			msg := fmt.Sprintf("Session '%s' not found", sessionID)
+			logger.Info(msg)
+
+			response.SetError(&models.Error{
+				Code:    fmt.Sprintf(s.errCodePrefix, "RV", 2),
+				Message: msg,
+				Detail:  err,
+			}).SetStatusCode(http.StatusNotFound).Send(ctx)
+			return
+		}
+
+		logger.Error("Failed to revoke session", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RV", 3),
+			Message: "Failed to revoke session",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).
+		SetMessage(fmt.Sprintf("Session '%s' revoked successfully", sessionID)).Send(ctx)
+}
+
+// RevokeOthers signs the user out everywhere except the session making the request
+func (s *sessionService) RevokeOthers(ctx *fasthttp.RequestCtx) {
+	var (
+		reqID     = utils.GetField(ctx, constants.CtxRequestID)
+		logger    = s.logger.With(zap.String("requestID", reqID))
+		response  = response.NewResponse(reqID, s.config.App.Server.Compression, logger)
+		tokenInfo = getTokenInfo(ctx)
+	)
+
+	if tokenInfo == nil || tokenInfo.UserID == 0 || tokenInfo.SessionID == "" {
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RO", 1),
+			Message: "Sign in required",
+		}).SetStatusCode(http.StatusUnauthorized).Send(ctx)
+		return
+	}
+
+	ctxVal, cancel := withTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if err := s.egressRepository.Session.DeleteByUserID(ctxVal, tokenInfo.UserID, tokenInfo.SessionID); err != nil {
+		logger.Error("Failed to revoke sessions", zap.Error(err))
+
+		response.SetError(&models.Error{
+			Code:    fmt.Sprintf(s.errCodePrefix, "RO", 2),
+			Message: "Failed to revoke sessions",
+			Detail:  err,
+		}).SetStatusCode(http.StatusInternalServerError).Send(ctx)
+		return
+	}
+
+	response.SetStatus(true).SetStatusCode(http.StatusOK).SetMessage("Other sessions revoked successfully").Send(ctx)
+}
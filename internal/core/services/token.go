@@ -1,30 +1,155 @@
 package services
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 )
 
 type tokenService struct {
-	config *models.Config
-	logger ports.Logger
+	config           *models.Config
+	logger           ports.Logger
+	egressRepository egress.Repository
+	permissions      *permissionCache
+	claimTemplates   *claimTemplates
 }
 
-func NewTokenService(config *models.Config, logger ports.Logger) ingress.TokenServicePorts {
+func NewTokenService(config *models.Config, logger ports.Logger, egressRepository egress.Repository) ingress.TokenServicePorts {
 	return &tokenService{
-		config: config,
-		logger: logger,
+		config:           config,
+		logger:           logger,
+		egressRepository: egressRepository,
+		permissions: &permissionCache{
+			indexes: make(map[string]*models.PermissionIndex),
+			roles:   make(map[constants.Roles]cachedRole),
+		},
+		claimTemplates: &claimTemplates{},
+	}
+}
+
+func (tk *tokenService) GenerateToken(roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), permissionLookupTimeout)
+	defer cancel()
+
+	return tk.encode(ctx, roleID, permissions, userInfo, opts)
+}
+
+func (tk *tokenService) encode(ctx context.Context, roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (string, error) {
+	claims, err := tk.claims(roleID, permissions, userInfo, opts)
+	if err != nil {
+		return "", err
 	}
+
+	if err := tk.compactPermissions(ctx, &claims); err != nil {
+		return "", err
+	}
+	return tk.egressRepository.TokenCodec.Encode(&claims)
 }
 
-func (tk *tokenService) GenerateToken(roleID constants.Roles, permissions []string, userInfo *models.User) (string, error) {
+// IssueToken generates an opaque token when the options or one of the audiences ask for it and
+// opaque tokens are enabled, a JWT otherwise
+func (tk *tokenService) IssueToken(ctx context.Context, roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (string, error) {
+	if !tk.opaque(opts) {
+		return tk.encode(ctx, roleID, permissions, userInfo, opts)
+	}
+
+	claims, err := tk.claims(roleID, permissions, userInfo, opts)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the hash is stored, a cache dump does not reveal usable tokens
+	var (
+		token = constants.OpaqueTokenPrefix + secret
+		key   = fmt.Sprintf(constants.CacheKeyOpaqueToken, utils.HashToken(token))
+	)
+	if err := tk.egressRepository.Cache.Add(ctx, key, claims, time.Until(claims.ExpiresAt.Time), constants.CacheAdd); err != nil {
+		return "", fmt.Errorf("failed to store opaque token: %w", err)
+	}
+	return token, nil
+}
+
+func (tk *tokenService) opaque(opts *models.TokenOptions) bool {
+	if tk.config.OpaqueTokens == nil || !tk.config.OpaqueTokens.Enabled || opts == nil {
+		return false
+	}
+
+	if opts.Format == constants.TokenFormatOpaque {
+		return true
+	}
+
+	for _, audience := range opts.Audience {
+		if slices.Contains(tk.config.OpaqueTokens.Audiences, audience) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveToken returns the claims of an opaque token from the cache and verifies any other token as a JWT
+func (tk *tokenService) ResolveToken(ctx context.Context, token string) (*models.Token, error) {
+	if !strings.HasPrefix(token, constants.OpaqueTokenPrefix) {
+		return tk.decode(ctx, token)
+	}
+
+	var claims models.Token
+	if _, err := tk.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyOpaqueToken, utils.HashToken(token)), &claims); err != nil {
+		if errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, utils.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
+		return nil, utils.ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// RevokeToken deletes an opaque token, a JWT is denied by its id until it expires
+func (tk *tokenService) RevokeToken(ctx context.Context, token string) error {
+	if strings.HasPrefix(token, constants.OpaqueTokenPrefix) {
+		return tk.egressRepository.Cache.Delete(ctx, fmt.Sprintf(constants.CacheKeyOpaqueToken, utils.HashToken(token)))
+	}
+
+	tokenInfo, err := tk.GetTokenInfo(token)
+	if err != nil {
+		return utils.ErrInvalidToken
+	}
+
+	ttl := time.Until(tokenInfo.ExpiresAt.Time)
+	if tokenInfo.ID == "" || ttl <= 0 {
+		return nil
+	}
+	return tk.egressRepository.Cache.Add(ctx, fmt.Sprintf(constants.CacheKeyRevokedToken, tokenInfo.ID), true, ttl, constants.CacheUpdate)
+}
+
+func (tk *tokenService) claims(roleID constants.Roles, permissions []string, userInfo *models.User, opts *models.TokenOptions) (models.Token, error) {
 	var (
 		userID int
 		now    = time.Now()
@@ -34,11 +159,24 @@ func (tk *tokenService) GenerateToken(roleID constants.Roles, permissions []stri
 		userID = userInfo.ID
 	}
 
+	if opts == nil {
+		opts = &models.TokenOptions{}
+	}
+
 	expiryAt := now.Add(tk.config.Jwt.LifeSpan)
+	if !opts.NotAfter.IsZero() && opts.NotAfter.Before(expiryAt) {
+		expiryAt = opts.NotAfter
+	}
 	claims := models.Token{
-		UserID:      userID,
-		Role:        roleID,
-		Permissions: sliceStringToMapStruct(permissions),
+		UserID:           userID,
+		Role:             roleID,
+		Permissions:      sliceStringToMapStruct(permissions),
+		SessionID:        opts.SessionID,
+		ServiceAccountID: opts.ServiceAccountID,
+		Acr:              opts.Acr,
+		Amr:              opts.Amr,
+		Cnf:              opts.Confirmation,
+		Act:              opts.Actor,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    tk.config.Jwt.Issuer,
 			Subject:   tk.config.Jwt.Subject,
@@ -50,8 +188,145 @@ func (tk *tokenService) GenerateToken(roleID constants.Roles, permissions []stri
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tk.config.Jwt.SecretKey))
+	if len(opts.Audience) > 0 {
+		claims.Audience = opts.Audience
+	}
+
+	if !opts.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(opts.AuthTime)
+	}
+
+	custom, err := tk.customClaims(models.NewClaimData(userInfo, roleID, permissions), claims.Audience, opts.ClaimMapping)
+	if err != nil {
+		return models.Token{}, err
+	}
+	claims.Custom = custom
+
+	return claims, nil
+}
+
+// customClaims evaluates the claim mappings of the audiences, then that of the client
+func (tk *tokenService) customClaims(data *models.ClaimData, audience []string, clientMapping models.ClaimMapping) (map[string]string, error) {
+	var mappings []models.ClaimMapping
+	if cfg := tk.config.ClaimMappings; cfg != nil {
+		for _, name := range audience {
+			if mapping, found := cfg.Audiences[name]; found {
+				mappings = append(mappings, mapping)
+			}
+		}
+	}
+	mappings = append(mappings, clientMapping)
+
+	var custom map[string]string
+	for _, mapping := range mappings {
+		for name, text := range mapping {
+			value, err := tk.claimTemplates.execute(name, text, data)
+			if err != nil {
+				return nil, fmt.Errorf("claim %q: %w", name, err)
+			}
+
+			if value == "" {
+				delete(custom, name)
+				continue
+			}
+			if custom == nil {
+				custom = make(map[string]string)
+			}
+			custom[name] = value
+		}
+	}
+	return custom, nil
+}
+
+// logoutTokenLifeSpan only needs to cover delivery, clients reject logout tokens older than that
+const logoutTokenLifeSpan = 2 * time.Minute
+
+// GenerateLogoutToken signs the logout token sent to a client when the session ends, it is a JWT
+// whatever the codec since clients expect one, see OpenID Connect Back-Channel Logout. It is
+// signed with the Ed25519 logout key, clients verify it without holding any gateway secret.
+func (tk *tokenService) GenerateLogoutToken(clientID string, userID int, sessionID string) (string, error) {
+	key, err := tk.logoutKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := models.LogoutToken{
+		SessionID: sessionID,
+		Events:    map[string]struct{}{constants.BackchannelLogoutEvent: {}},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tk.config.Jwt.Issuer,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(logoutTokenLifeSpan)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["typ"] = constants.LogoutTokenType
+	token.Header["kid"] = logoutKeyID(key.Public().(ed25519.PublicKey))
+	return token.SignedString(key)
+}
+
+// LogoutKeys returns the public key verifying logout tokens
+func (tk *tokenService) LogoutKeys() (*models.JwkSet, error) {
+	key, err := tk.logoutKey()
+	if err != nil {
+		return nil, err
+	}
+
+	public := key.Public().(ed25519.PublicKey)
+	return &models.JwkSet{Keys: []models.Jwk{{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(public),
+		Kid: logoutKeyID(public),
+		Use: "sig",
+		Alg: jwt.SigningMethodEdDSA.Alg(),
+	}}}, nil
+}
+
+func (tk *tokenService) logoutKey() (ed25519.PrivateKey, error) {
+	if tk.config.Sso == nil {
+		return nil, errors.New("logout signing key is not configured")
+	}
+
+	seed, err := hex.DecodeString(tk.config.Sso.LogoutSigningKey)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid logout signing key")
+	}
+
+	// The zero seed is a well known key, anyone could forge logout tokens with it
+	if slices.Equal(seed, make([]byte, ed25519.SeedSize)) {
+		return nil, errors.New("logout signing key must not be all zeros")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// logoutKeyID is the RFC 7638 thumbprint of the key
+func logoutKeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(public))))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// isLogoutToken reports whether the header of the token declares a logout token
+func isLogoutToken(token string) bool {
+	header, _, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+
+	var fields struct {
+		Typ string `json:"typ"`
+	}
+	return json.Unmarshal(raw, &fields) == nil && strings.EqualFold(fields.Typ, constants.LogoutTokenType)
 }
 
 // HavePermission checks if the token contains the required permission
@@ -61,33 +336,54 @@ func (tk *tokenService) HavePermission(token, permission string) bool {
 		return false
 	}
 
-	_, found := tokenInfo.Permissions[permission]
-	return found
+	return tokenInfo.HasPermission(permission)
 }
 
-// GetTokenInfo parses the JWT token and extracts the token claims
+// GetTokenInfo decodes the token with the configured codec and extracts the token claims
 func (tk *tokenService) GetTokenInfo(token string) (*models.Token, error) {
-	var claims models.Token
+	ctx, cancel := context.WithTimeout(context.Background(), permissionLookupTimeout)
+	defer cancel()
 
-	parsedToken, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is HMAC
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return []byte(tk.config.Jwt.SecretKey), nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("token parsing failed: %w", err)
-	}
+	return tk.decode(ctx, token)
+}
 
-	if !parsedToken.Valid {
-		return nil, fmt.Errorf("invalid token")
+// decode verifies the token and expands compact permissions, only lookup failures are not ErrInvalidToken
+func (tk *tokenService) decode(ctx context.Context, token string) (*models.Token, error) {
+	// Logout tokens are handed to clients, they never authorize a request
+	if isLogoutToken(token) {
+		return nil, fmt.Errorf("%w: logout token", utils.ErrInvalidToken)
 	}
 
-	// Additional type assertion to ensure safe usage
-	if _, ok := parsedToken.Claims.(*models.Token); !ok {
-		return nil, fmt.Errorf("invalid token claims type")
+	var claims models.Token
+	if err := tk.egressRepository.TokenCodec.Decode(token, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidToken, err)
 	}
 
+	if err := tk.expandPermissions(ctx, &claims); err != nil {
+		return nil, err
+	}
 	return &claims, nil
 }
+
+// claimTemplates compiles each claim template once
+type claimTemplates struct {
+	compiled sync.Map
+}
+
+func (c *claimTemplates) execute(name, text string, data *models.ClaimData) (string, error) {
+	key := name + "\x00" + text
+	tmpl, found := c.compiled.Load(key)
+	if !found {
+		parsed, err := models.ParseClaimTemplate(name, text)
+		if err != nil {
+			return "", err
+		}
+		tmpl, _ = c.compiled.LoadOrStore(key, parsed)
+	}
+
+	var out strings.Builder
+	if err := tmpl.(*template.Template).Execute(&out, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+)
+
+const (
+	// permissionCacheTTL bounds how long a changed permission list or role takes to reach tokens
+	permissionCacheTTL = time.Minute
+	// permissionLookupTimeout bounds the lookups of callers without a context
+	permissionLookupTimeout = 5 * time.Second
+)
+
+// permissionCache keeps the current permission index, the older versions still in use and the
+// permissions of roles
+type permissionCache struct {
+	mu       sync.Mutex
+	current  *models.PermissionIndex
+	loadedAt time.Time
+	indexes  map[string]*models.PermissionIndex
+	roles    map[constants.Roles]cachedRole
+}
+
+type cachedRole struct {
+	permissions []string
+	loadedAt    time.Time
+}
+
+// PermissionIndex returns the index of the version, the current one when version is empty
+func (tk *tokenService) PermissionIndex(ctx context.Context, version string) (*models.PermissionIndex, error) {
+	if version == "" {
+		return tk.currentIndex(ctx)
+	}
+	return tk.indexVersion(ctx, version)
+}
+
+// currentIndex numbers the permissions in name order. Every reload keeps the snapshot of its
+// version in the cache for a token life span, tokens encoded with it are decoded until they expire.
+func (tk *tokenService) currentIndex(ctx context.Context) (*models.PermissionIndex, error) {
+	tk.permissions.mu.Lock()
+	defer tk.permissions.mu.Unlock()
+
+	if tk.permissions.current != nil && time.Since(tk.permissions.loadedAt) < permissionCacheTTL {
+		return tk.permissions.current, nil
+	}
+
+	permissions, err := tk.egressRepository.Permission.GetPermissionWithoutPagination(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.ID)
+	}
+	slices.Sort(names)
+
+	index := &models.PermissionIndex{
+		Version:     utils.HashToken(strings.Join(names, ","))[:12],
+		Permissions: names,
+	}
+
+	key := fmt.Sprintf(constants.CacheKeyPermissionIndex, index.Version)
+	if err := tk.egressRepository.Cache.Add(ctx, key, index, tk.config.Jwt.LifeSpan+permissionCacheTTL, constants.CacheUpdate); err != nil {
+		return nil, fmt.Errorf("failed to publish permission index: %w", err)
+	}
+
+	tk.permissions.current, tk.permissions.loadedAt = index, time.Now()
+	tk.permissions.indexes[index.Version] = index
+	return index, nil
+}
+
+func (tk *tokenService) indexVersion(ctx context.Context, version string) (*models.PermissionIndex, error) {
+	tk.permissions.mu.Lock()
+	index, found := tk.permissions.indexes[version]
+	tk.permissions.mu.Unlock()
+	if found {
+		return index, nil
+	}
+
+	index = &models.PermissionIndex{}
+	if _, err := tk.egressRepository.Cache.Get(ctx, fmt.Sprintf(constants.CacheKeyPermissionIndex, version), index); err != nil {
+		if errors.Is(err, utils.ErrInvalidCacheKey) {
+			return nil, utils.ErrDocumentNotFound
+		}
+		return nil, err
+	}
+
+	tk.permissions.mu.Lock()
+	tk.permissions.indexes[version] = index
+	tk.permissions.mu.Unlock()
+	return index, nil
+}
+
+// rolePermissions returns the effective permissions of an active role, none for any other
+func (tk *tokenService) rolePermissions(ctx context.Context, roleID constants.Roles) ([]string, error) {
+	tk.permissions.mu.Lock()
+	role, found := tk.permissions.roles[roleID]
+	tk.permissions.mu.Unlock()
+	if found && time.Since(role.loadedAt) < permissionCacheTTL {
+		return role.permissions, nil
+	}
+
+	stored, err := tk.egressRepository.Role.GetByID(ctx, roleID)
+	if err != nil && !errors.Is(err, utils.ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	role = cachedRole{loadedAt: time.Now()}
+	if stored != nil && stored.Status == constants.StatusActive {
+		if role.permissions, err = inheritedPermissions(ctx, tk.egressRepository.Role, stored); err != nil {
+			return nil, err
+		}
+	}
+
+	tk.permissions.mu.Lock()
+	tk.permissions.roles[roleID] = role
+	tk.permissions.mu.Unlock()
+	return role.permissions, nil
+}
+
+// compactPermissions rewrites the permissions of the claims in the configured encoding. A role
+// reference is only used when the token has exactly the permissions of its role, other tokens
+// fall back to the bitmap.
+func (tk *tokenService) compactPermissions(ctx context.Context, claims *models.Token) error {
+	encoding := tk.config.Jwt.PermissionEncoding
+	if encoding == "" || encoding == constants.PermissionEncodingList || len(claims.Permissions) == 0 {
+		return nil
+	}
+
+	if encoding == constants.PermissionEncodingRole && claims.Role != "" {
+		rolePermissions, err := tk.rolePermissions(ctx, claims.Role)
+		if err != nil {
+			return err
+		}
+
+		if samePermissions(claims.Permissions, rolePermissions) {
+			claims.Permissions, claims.RolePermissions = nil, true
+			return nil
+		}
+	}
+
+	index, err := tk.currentIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	bits := make([]byte, (len(index.Permissions)+7)/8)
+	for i, permission := range index.Permissions {
+		if _, found := claims.Permissions[permission]; found {
+			bits[i/8] |= 1 << (i % 8)
+			delete(claims.Permissions, permission)
+		}
+	}
+
+	claims.PermissionBitmap = &models.PermissionBitmap{
+		Version: index.Version,
+		Bits:    base64.RawURLEncoding.EncodeToString(bits),
+	}
+	return nil
+}
+
+// expandPermissions restores the permissions of compact claims, whatever the configured encoding
+func (tk *tokenService) expandPermissions(ctx context.Context, claims *models.Token) error {
+	if claims.Permissions == nil {
+		claims.Permissions = make(map[string]struct{})
+	}
+
+	if claims.RolePermissions {
+		rolePermissions, err := tk.rolePermissions(ctx, claims.Role)
+		if err != nil {
+			return err
+		}
+		for _, permission := range rolePermissions {
+			claims.Permissions[permission] = struct{}{}
+		}
+	}
+
+	if bitmap := claims.PermissionBitmap; bitmap != nil {
+		index, err := tk.indexVersion(ctx, bitmap.Version)
+		if err != nil {
+			if errors.Is(err, utils.ErrDocumentNotFound) {
+				return fmt.Errorf("%w: unknown permission index %q", utils.ErrInvalidToken, bitmap.Version)
+			}
+			return err
+		}
+
+		bits, err := base64.RawURLEncoding.DecodeString(bitmap.Bits)
+		if err != nil || len(bits) > (len(index.Permissions)+7)/8 {
+			return fmt.Errorf("%w: malformed permission bitmap", utils.ErrInvalidToken)
+		}
+
+		for i, permission := range index.Permissions {
+			if i/8 < len(bits) && bits[i/8]&(1<<(i%8)) != 0 {
+				claims.Permissions[permission] = struct{}{}
+			}
+		}
+	}
+
+	return nil
+}
+
+func samePermissions(granted map[string]struct{}, permissions []string) bool {
+	unique := sliceStringToMapStruct(permissions)
+	if len(unique) != len(granted) {
+		return false
+	}
+
+	for permission := range unique {
+		if _, found := granted[permission]; !found {
+			return false
+		}
+	}
+	return true
+}
@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/valyala/fasthttp"
 )
 
 // Slice of stling to map[strng]struct{}
@@ -17,3 +21,53 @@ func sliceStringToMapStruct(perms []string) map[string]struct{} {
 func withTimeout(ctx context.Context, duration time.Duration) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(ctx, duration)
 }
+
+// getTokenInfo returns the claims stored by the Authorization middleware
+func getTokenInfo(ctx *fasthttp.RequestCtx) *models.Token {
+	tokenInfo, _ := ctx.UserValue(constants.CtxTokenInfo).(*models.Token)
+	return tokenInfo
+}
+
+// setCookie sets a cookie, an empty value with a past expiry clears it
+func setCookie(ctx *fasthttp.RequestCtx, name, value, domain string, secure, httpOnly bool, sameSite fasthttp.CookieSameSite, expiresAt time.Time) {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+
+	cookie.SetKey(name)
+	cookie.SetValue(value)
+	cookie.SetPath("/")
+	cookie.SetDomain(domain)
+	cookie.SetHTTPOnly(httpOnly)
+	cookie.SetSecure(secure)
+	cookie.SetSameSite(sameSite)
+	cookie.SetExpire(expiresAt)
+
+	ctx.Response.Header.SetCookie(cookie)
+}
+
+// acrFor derives the authentication class from the methods used
+func acrFor(amr []string) constants.Acr {
+	if len(amr) > 1 {
+		return constants.AcrMultiFactor
+	}
+	return constants.AcrSingleFactor
+}
+
+func sameSiteMode(mode string) fasthttp.CookieSameSite {
+	switch mode {
+	case "strict":
+		return fasthttp.CookieSameSiteStrictMode
+	case "none":
+		return fasthttp.CookieSameSiteNoneMode
+	default:
+		return fasthttp.CookieSameSiteLaxMode
+	}
+}
+
+// actorOf returns the user behind the request, zero when unknown
+func actorOf(ctx *fasthttp.RequestCtx) int {
+	if tokenInfo := getTokenInfo(ctx); tokenInfo != nil {
+		return tokenInfo.UserID
+	}
+	return 0
+}
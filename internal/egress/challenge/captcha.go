@@ -0,0 +1,72 @@
+package challenge
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+)
+
+type captcha struct {
+	provider   constants.ChallengeProvider
+	verifyURL  string
+	config     *models.CaptchaChallenge
+	httpClient egress.HttpClientPorts
+}
+
+// NewCaptcha returns a reCAPTCHA or hCaptcha adapter, both share the siteverify protocol
+func NewCaptcha(provider constants.ChallengeProvider, config *models.CaptchaChallenge, httpClient egress.HttpClientPorts) egress.ChallengePorts {
+	verifyURL := config.VerifyURL
+	if verifyURL == "" {
+		verifyURL = constants.RecaptchaVerifyURL
+		if provider == constants.ChallengeHcaptcha {
+			verifyURL = constants.HcaptchaVerifyURL
+		}
+	}
+
+	return &captcha{
+		provider:   provider,
+		verifyURL:  verifyURL,
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+func (c *captcha) Issue(ctx context.Context) (*models.Challenge, error) {
+	return &models.Challenge{
+		Provider: c.provider,
+		SiteKey:  c.config.SiteKey,
+	}, nil
+}
+
+func (c *captcha) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return utils.ErrInvalidChallenge
+	}
+
+	form := url.Values{}
+	form.Set("secret", c.config.SecretKey)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	var result models.CaptchaVerifyResponse
+	if err := c.httpClient.ExecuteForm(c.verifyURL, form, &result); err != nil {
+		return fmt.Errorf("%s verify: %w", c.provider, err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidChallenge, result.ErrorCodes)
+	}
+
+	if c.config.MinScore > 0 && c.provider == constants.ChallengeRecaptcha && result.Score < c.config.MinScore {
+		return fmt.Errorf("%w: score %.2f below %.2f", utils.ErrInvalidChallenge, result.Score, c.config.MinScore)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package challenge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/google/uuid"
+)
+
+type powPayload struct {
+	ID         string `json:"id"`
+	Nonce      string `json:"nonce"`
+	Difficulty int    `json:"difficulty"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+type pow struct {
+	config     *models.PowChallenge
+	repository egress.ChallengeRepositoryPorts
+}
+
+// NewPow returns a self-hosted proof of work challenge.
+// Challenges are signed and stateless until solved, the cache only tracks used ones.
+func NewPow(config *models.PowChallenge, repository egress.ChallengeRepositoryPorts) egress.ChallengePorts {
+	return &pow{
+		config:     config,
+		repository: repository,
+	}
+}
+
+func (p *pow) Issue(ctx context.Context) (*models.Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("pow issue: failed to generate nonce: %w", err)
+	}
+
+	expiresAt := time.Now().Add(p.config.LifeSpan)
+	data, err := json.Marshal(powPayload{
+		ID:         uuid.NewString(),
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+		Difficulty: p.config.Difficulty,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pow issue: failed to marshal payload: %w", err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return &models.Challenge{
+		Provider:   constants.ChallengePow,
+		Challenge:  payload + "." + p.sign(payload),
+		Difficulty: p.config.Difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// Verify expects "<payload>.<signature>:<counter>"
+func (p *pow) Verify(ctx context.Context, token, remoteIP string) error {
+	challenge, counter, found := strings.Cut(token, ":")
+	if !found || counter == "" {
+		return utils.ErrInvalidChallenge
+	}
+
+	payload, signature, found := strings.Cut(challenge, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(p.sign(payload))) {
+		return utils.ErrInvalidChallenge
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return utils.ErrInvalidChallenge
+	}
+
+	var claims powPayload
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return utils.ErrInvalidChallenge
+	}
+
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return utils.ErrInvalidChallenge
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	if leadingZeroBits(hash[:]) < claims.Difficulty {
+		return utils.ErrInvalidChallenge
+	}
+
+	fresh, err := p.repository.Consume(ctx, claims.ID, ttl)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return utils.ErrInvalidChallenge
+	}
+
+	return nil
+}
+
+func (p *pow) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(p.config.SecretKey))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
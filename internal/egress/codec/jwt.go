@@ -0,0 +1,38 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type jwtCodec struct {
+	secretKey []byte
+}
+
+func NewJwt(secretKey string) egress.TokenCodecPorts {
+	return &jwtCodec{
+		secretKey: []byte(secretKey),
+	}
+}
+
+func (c *jwtCodec) Encode(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(c.secretKey)
+}
+
+func (c *jwtCodec) Decode(token string, claims jwt.Claims) error {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	parsedToken, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return c.secretKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("token parsing failed: %w", err)
+	}
+
+	if !parsedToken.Valid {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
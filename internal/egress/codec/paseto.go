@@ -0,0 +1,243 @@
+package codec
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	pasetoPublicHeader = "v4.public."
+	pasetoLocalHeader  = "v4.local."
+
+	pasetoNonceSize = 32
+	pasetoTagSize   = 32
+)
+
+// PASETO registered claims are RFC 3339 strings where JWT uses numeric dates
+var pasetoTimeClaims = []string{"exp", "nbf", "iat"}
+
+var errInvalidPaseto = errors.New("invalid token")
+
+type paseto struct {
+	purpose    constants.TokenCodec
+	key        []byte // Symmetric key of v4.local
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// NewPaseto returns a PASETO v4 codec, the key is the 32 byte symmetric key of v4.local or the
+// Ed25519 seed of v4.public. Footers and implicit assertions are not used.
+func NewPaseto(purpose constants.TokenCodec, key []byte) (egress.TokenCodecPorts, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("paseto key must be 32 bytes, got %d", len(key))
+	}
+
+	codec := &paseto{purpose: purpose}
+	switch purpose {
+	case constants.CodecPasetoLocal:
+		codec.key = key
+	case constants.CodecPasetoPublic:
+		codec.privateKey = ed25519.NewKeyFromSeed(key)
+		codec.publicKey = codec.privateKey.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported paseto purpose %q", purpose)
+	}
+	return codec, nil
+}
+
+func (p *paseto) Encode(claims jwt.Claims) (string, error) {
+	message, err := pasetoPayload(claims)
+	if err != nil {
+		return "", err
+	}
+
+	if p.purpose == constants.CodecPasetoPublic {
+		return p.sign(message), nil
+	}
+
+	nonce := make([]byte, pasetoNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate paseto nonce: %w", err)
+	}
+	return p.encrypt(message, nonce)
+}
+
+func (p *paseto) sign(message []byte) string {
+	signature := ed25519.Sign(p.privateKey, pae([]byte(pasetoPublicHeader), message, nil, nil))
+	return pasetoPublicHeader + base64.RawURLEncoding.EncodeToString(append(message, signature...))
+}
+
+func (p *paseto) encrypt(message, nonce []byte) (string, error) {
+	encryptionKey, counterNonce, authKey := p.localKeys(nonce)
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(message))
+	cipher.XORKeyStream(ciphertext, message)
+
+	tag := pasetoHash(authKey, pasetoTagSize, pae([]byte(pasetoLocalHeader), nonce, ciphertext, nil, nil))
+
+	payload := make([]byte, 0, len(nonce)+len(ciphertext)+len(tag))
+	payload = append(append(append(payload, nonce...), ciphertext...), tag...)
+	return pasetoLocalHeader + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func (p *paseto) Decode(token string, claims jwt.Claims) error {
+	message, err := p.open(token)
+	if err != nil {
+		return err
+	}
+
+	payload, err := jwtPayload(message)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidPaseto, err)
+	}
+	return claims.Valid()
+}
+
+// open verifies or decrypts the token and returns its message
+func (p *paseto) open(token string) ([]byte, error) {
+	header := pasetoLocalHeader
+	if p.purpose == constants.CodecPasetoPublic {
+		header = pasetoPublicHeader
+	}
+
+	// Tokens with a footer have a fourth part, none are issued
+	body, found := strings.CutPrefix(token, header)
+	if !found || strings.Contains(body, ".") {
+		return nil, errInvalidPaseto
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, errInvalidPaseto
+	}
+
+	if p.purpose == constants.CodecPasetoPublic {
+		if len(raw) < ed25519.SignatureSize {
+			return nil, errInvalidPaseto
+		}
+		message, signature := raw[:len(raw)-ed25519.SignatureSize], raw[len(raw)-ed25519.SignatureSize:]
+		if !ed25519.Verify(p.publicKey, pae([]byte(header), message, nil, nil), signature) {
+			return nil, errInvalidPaseto
+		}
+		return message, nil
+	}
+
+	if len(raw) < pasetoNonceSize+pasetoTagSize {
+		return nil, errInvalidPaseto
+	}
+	nonce, ciphertext, tag := raw[:pasetoNonceSize], raw[pasetoNonceSize:len(raw)-pasetoTagSize], raw[len(raw)-pasetoTagSize:]
+
+	encryptionKey, counterNonce, authKey := p.localKeys(nonce)
+	expected := pasetoHash(authKey, pasetoTagSize, pae([]byte(header), nonce, ciphertext, nil, nil))
+	if subtle.ConstantTimeCompare(tag, expected) != 1 {
+		return nil, errInvalidPaseto
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(encryptionKey, counterNonce)
+	if err != nil {
+		return nil, err
+	}
+	message := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(message, ciphertext)
+	return message, nil
+}
+
+// localKeys splits the key of the token into its encryption key, XChaCha20 nonce and authentication key
+func (p *paseto) localKeys(nonce []byte) ([]byte, []byte, []byte) {
+	derived := pasetoHash(p.key, 56, append([]byte("paseto-encryption-key"), nonce...))
+	authKey := pasetoHash(p.key, 32, append([]byte("paseto-auth-key-for-aead"), nonce...))
+	return derived[:32], derived[32:], authKey
+}
+
+func pasetoHash(key []byte, size int, message []byte) []byte {
+	h, _ := blake2b.New(size, key) // Only fails for sizes and keys longer than 64 bytes
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// pae is the pre-authentication encoding of PASETO, every piece is prefixed with its length
+func pae(pieces ...[]byte) []byte {
+	out := binary.LittleEndian.AppendUint64(nil, uint64(len(pieces)))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece))&(1<<63-1))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// pasetoPayload encodes the claims, rewriting the numeric dates as RFC 3339
+func pasetoPayload(claims jwt.Claims) ([]byte, error) {
+	return convertTimeClaims(claims, func(value json.RawMessage) (any, error) {
+		var seconds int64
+		if err := json.Unmarshal(value, &seconds); err != nil {
+			return nil, err
+		}
+		return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+	})
+}
+
+// jwtPayload rewrites the RFC 3339 dates of a PASETO message as numeric dates
+func jwtPayload(message []byte) ([]byte, error) {
+	return convertTimeClaims(json.RawMessage(message), func(value json.RawMessage) (any, error) {
+		var date string
+		if err := json.Unmarshal(value, &date); err != nil {
+			return nil, err
+		}
+		parsed, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return nil, err
+		}
+		return parsed.Unix(), nil
+	})
+}
+
+func convertTimeClaims(value any, convert func(json.RawMessage) (any, error)) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPaseto, err)
+	}
+
+	for _, name := range pasetoTimeClaims {
+		raw, found := fields[name]
+		if !found {
+			continue
+		}
+
+		converted, err := convert(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: claim %s: %v", errInvalidPaseto, name, err)
+		}
+		if fields[name], err = json.Marshal(converted); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(fields)
+}
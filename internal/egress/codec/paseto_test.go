@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+)
+
+// Vectors of https://github.com/paseto-standard/test-vectors/blob/master/v4.json without footer
+// or implicit assertion, the only form issued by the codec
+func TestPasetoVectors(t *testing.T) {
+	const (
+		localKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+		publicKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" // Seed half of the secret key
+		zeroNonce = "0000000000000000000000000000000000000000000000000000000000000000"
+
+		signedMessage = `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`
+		secretMessage = `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`
+		hiddenMessage = `{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`
+	)
+
+	tests := []struct {
+		name    string
+		purpose constants.TokenCodec
+		key     string
+		nonce   string
+		payload string
+		token   string
+	}{
+		{
+			name:    "4-E-1",
+			purpose: constants.CodecPasetoLocal,
+			key:     localKey,
+			nonce:   zeroNonce,
+			payload: secretMessage,
+			token:   "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+		},
+		{
+			name:    "4-E-2",
+			purpose: constants.CodecPasetoLocal,
+			key:     localKey,
+			nonce:   zeroNonce,
+			payload: hiddenMessage,
+			token:   "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvS2csCgglvpk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XIemu9chy3WVKvRBfg6t8wwYHK0ArLxxfZP73W_vfwt5A",
+		},
+		{
+			name:    "4-S-1",
+			purpose: constants.CodecPasetoPublic,
+			key:     publicKey,
+			payload: signedMessage,
+			token:   "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := NewPaseto(tt.purpose, mustHex(t, tt.key))
+			if err != nil {
+				t.Fatalf("NewPaseto: %v", err)
+			}
+			p := codec.(*paseto)
+
+			// Sealing is deterministic given the nonce, Ed25519 signatures always are
+			var token string
+			if tt.purpose == constants.CodecPasetoLocal {
+				token, err = p.encrypt([]byte(tt.payload), mustHex(t, tt.nonce))
+			} else {
+				token, err = p.sign([]byte(tt.payload)), nil
+			}
+			if err != nil {
+				t.Fatalf("seal: %v", err)
+			}
+			if token != tt.token {
+				t.Errorf("seal:\n got  %s\n want %s", token, tt.token)
+			}
+
+			message, err := p.open(tt.token)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if string(message) != tt.payload {
+				t.Errorf("open:\n got  %s\n want %s", message, tt.payload)
+			}
+
+			// Flipping a bit of the last character breaks the tag or the signature
+			tampered := []byte(tt.token)
+			tampered[len(tampered)-1] ^= 1
+			if _, err := p.open(string(tampered)); err == nil {
+				t.Error("open accepted a tampered token")
+			}
+		})
+	}
+}
+
+func mustHex(t *testing.T, value string) []byte {
+	t.Helper()
+
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", value, err)
+	}
+	return decoded
+}
@@ -57,6 +57,8 @@ func (d *database) Connect() (*gorm.DB, error) {
 	for attempt := 1; attempt <= d.config.ConnectRetries; attempt++ {
 		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
 			Logger: gormLogger,
+			// Maps unique violations to gorm.ErrDuplicatedKey for the repositories
+			TranslateError: true,
 		})
 		if err == nil {
 			// Verify the connection is actually alive
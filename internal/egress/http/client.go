@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
@@ -48,15 +50,43 @@ func (h *httpClient) Execute(url, method string, reqPayload io.Reader, resPayloa
 	}
 	defer resp.Body.Close()
 
-	if resPayload != nil {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("http execute: failed to read response body: %w", err)
-		}
+	return decodeResponse(resp, resPayload)
+}
 
-		if err := json.Unmarshal(bodyBytes, resPayload); err != nil {
-			return fmt.Errorf("http execute: failed to unmarshal response: %w", err)
-		}
+// ExecuteForm posts form-encoded values, as expected by siteverify and logout endpoints.
+// Non 2xx responses are returned as errors.
+func (h *httpClient) ExecuteForm(url string, form url.Values, resPayload any) error {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("http execute form: failed to create request: %w", err)
+	}
+	req.Header.Set(constants.ContentType.String(), constants.FormUrlEncoded.String())
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http execute form: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("http execute form: unexpected status %d", resp.StatusCode)
+	}
+
+	return decodeResponse(resp, resPayload)
+}
+
+func decodeResponse(resp *http.Response, resPayload any) error {
+	if resPayload == nil {
+		return nil
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("http execute: failed to read response body: %w", err)
+	}
+
+	if err := json.Unmarshal(bodyBytes, resPayload); err != nil {
+		return fmt.Errorf("http execute: failed to unmarshal response: %w", err)
 	}
 	return nil
 }
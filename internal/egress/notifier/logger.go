@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"go.uber.org/zap"
+)
+
+type logSender struct {
+	logger      ports.Logger
+	environment constants.Environment
+}
+
+// NewLogSender writes OTP codes to the log in development. It refuses to deliver
+// anything in other environments, where an SMS or email adapter has to be configured.
+func NewLogSender(logger ports.Logger, environment constants.Environment) egress.OtpSenderPorts {
+	return &logSender{
+		logger:      logger,
+		environment: environment,
+	}
+}
+
+func (l *logSender) Send(ctx context.Context, user *models.User, code string) error {
+	if l.environment != constants.Development {
+		return errors.New("otp sender: no delivery channel configured")
+	}
+
+	l.logger.InfoCtx(ctx, "OTP generated", zap.Int("userID", user.ID), zap.String("code", code))
+	return nil
+}
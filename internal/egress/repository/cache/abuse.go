@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+type abuse struct {
+	prefix string
+	client *redis.Client
+}
+
+func NewAbuseRepository(prefix string, client *redis.Client) egress.AbuseRepositoryPorts {
+	return &abuse{
+		prefix: prefix,
+		client: client,
+	}
+}
+
+func (a *abuse) sourceKey(source string) string {
+	return fmt.Sprintf("%s:source:%s", a.prefix, source)
+}
+
+func (a *abuse) accountKey(account string) string {
+	return fmt.Sprintf("%s:account:%s", a.prefix, account)
+}
+
+func (a *abuse) blockKey(source string) string {
+	return fmt.Sprintf("%s:blocked:%s", a.prefix, source)
+}
+
+func (a *abuse) blockIndexKey() string {
+	return fmt.Sprintf("%s:blocked", a.prefix)
+}
+
+// RecordFailure adds the failure to both rolling windows and returns the distinct counts.
+// Each window is a sorted set scored by the last failure time of its member.
+func (a *abuse) RecordFailure(ctx context.Context, source, account string, window time.Duration) (int, int, error) {
+	var (
+		now       = time.Now()
+		score     = float64(now.UnixMilli())
+		threshold = strconv.FormatInt(now.Add(-window).UnixMilli(), 10)
+		pipe      = a.client.TxPipeline()
+	)
+
+	pipe.ZAdd(ctx, a.sourceKey(source), redis.Z{Score: score, Member: account})
+	pipe.ZRemRangeByScore(ctx, a.sourceKey(source), "-inf", "("+threshold)
+	pipe.PExpire(ctx, a.sourceKey(source), window)
+	sourceAccounts := pipe.ZCard(ctx, a.sourceKey(source))
+
+	pipe.ZAdd(ctx, a.accountKey(account), redis.Z{Score: score, Member: source})
+	pipe.ZRemRangeByScore(ctx, a.accountKey(account), "-inf", "("+threshold)
+	pipe.PExpire(ctx, a.accountKey(account), window)
+	accountSources := pipe.ZCard(ctx, a.accountKey(account))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to record signin failure: %w", err)
+	}
+
+	return int(sourceAccounts.Val()), int(accountSources.Val()), nil
+}
+
+func (a *abuse) Counts(ctx context.Context, source, account string, window time.Duration) (int, int, error) {
+	var (
+		min  = strconv.FormatInt(time.Now().Add(-window).UnixMilli(), 10)
+		pipe = a.client.Pipeline()
+	)
+
+	sourceAccounts := pipe.ZCount(ctx, a.sourceKey(source), min, "+inf")
+	accountSources := pipe.ZCount(ctx, a.accountKey(account), min, "+inf")
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to count signin failures: %w", err)
+	}
+
+	return int(sourceAccounts.Val()), int(accountSources.Val()), nil
+}
+
+func (a *abuse) Block(ctx context.Context, blocked *models.BlockedSource) error {
+	data, err := json.Marshal(blocked)
+	if err != nil {
+		return fmt.Errorf("failed to marshal blocked source %q: %w", blocked.Source, err)
+	}
+
+	pipe := a.client.TxPipeline()
+	pipe.Set(ctx, a.blockKey(blocked.Source), data, time.Until(blocked.ExpiresAt))
+	pipe.ZAdd(ctx, a.blockIndexKey(), redis.Z{Score: float64(blocked.ExpiresAt.Unix()), Member: blocked.Source})
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to block source %q: %w", blocked.Source, err)
+	}
+	return nil
+}
+
+func (a *abuse) GetBlock(ctx context.Context, source string) (*models.BlockedSource, error) {
+	result, err := a.client.Get(ctx, a.blockKey(source)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, utils.ErrDocumentNotFound
+		}
+		return nil, fmt.Errorf("failed to get blocked source %q: %w", source, err)
+	}
+
+	var blocked models.BlockedSource
+	if err := json.Unmarshal([]byte(result), &blocked); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal blocked source %q: %w", source, err)
+	}
+	return &blocked, nil
+}
+
+func (a *abuse) ListBlocked(ctx context.Context) ([]models.BlockedSource, error) {
+	// Drop index entries whose block has already expired
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := a.client.ZRemRangeByScore(ctx, a.blockIndexKey(), "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune blocked sources: %w", err)
+	}
+
+	sources, err := a.client.ZRange(ctx, a.blockIndexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked sources: %w", err)
+	}
+
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(sources))
+	for _, source := range sources {
+		keys = append(keys, a.blockKey(source))
+	}
+
+	values, err := a.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocked sources: %w", err)
+	}
+
+	blocked := make([]models.BlockedSource, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var item models.BlockedSource
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal blocked source: %w", err)
+		}
+		blocked = append(blocked, item)
+	}
+
+	return blocked, nil
+}
+
+// Unblock deletes the block and the failures of the source, they would block it again otherwise
+func (a *abuse) Unblock(ctx context.Context, source string) error {
+	pipe := a.client.TxPipeline()
+	deleted := pipe.Del(ctx, a.blockKey(source))
+	pipe.ZRem(ctx, a.blockIndexKey(), source)
+	pipe.Del(ctx, a.sourceKey(source))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to unblock source %q: %w", source, err)
+	}
+
+	if deleted.Val() == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+// MarkAlerted returns true only for the first caller within ttl, used to de-duplicate alerts
+func (a *abuse) MarkAlerted(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := a.client.SetNX(ctx, fmt.Sprintf("%s:alerted:%s", a.prefix, key), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark alert %q: %w", key, err)
+	}
+	return ok, nil
+}
+
+func (a *abuse) PublishAlert(ctx context.Context, channel string, event *models.AlertEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert event: %w", err)
+	}
+
+	if err := a.client.Publish(ctx, channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish alert event: %w", err)
+	}
+	return nil
+}
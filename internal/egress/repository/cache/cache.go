@@ -9,6 +9,7 @@ import (
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
 	"github.com/redis/go-redis/v9"
 )
@@ -18,7 +19,7 @@ type cache struct {
 	client *redis.Client
 }
 
-func NewCacheRepository(config *models.Cache, client *redis.Client) *cache {
+func NewCacheRepository(config *models.Cache, client *redis.Client) egress.CacheRepositoryPorts {
 	return &cache{
 		config: config,
 		client: client,
@@ -47,6 +48,25 @@ func (c *cache) Get(ctx context.Context, key string, response any) (string, erro
 	return result, nil
 }
 
+// GetDel fetches and removes a value in one step, so only one caller can ever consume it.
+func (c *cache) GetDel(ctx context.Context, key string, response any) error {
+	result, err := c.client.GetDel(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return utils.ErrInvalidCacheKey
+		}
+		return fmt.Errorf("failed to get and delete cache key %q: %w", key, err)
+	}
+
+	if response != nil {
+		if err := json.Unmarshal([]byte(result), response); err != nil {
+			return fmt.Errorf("failed to unmarshal cache key %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
 // Add stores or updates a value based on the provided strategy.
 //   - CacheAdd: Only set if the key does not exist.
 //   - CacheUpdate: Always set (overwrite if exists).
@@ -74,3 +94,32 @@ func (c *cache) Add(ctx context.Context, key string, value any, ttl time.Duratio
 
 	return nil
 }
+
+// incrScript increments the counter and starts its expiry with the first increment
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Incr atomically increments the counter of a fixed window, which opens with the first increment
+// and lasts ttl. Get reads the counter without incrementing it.
+func (c *cache) Incr(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	count, err := incrScript.Run(ctx, c.client, []string{key}, ttl.Milliseconds()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment cache key %q: %w", key, err)
+	}
+
+	return count, nil
+}
+
+// Delete removes the key, missing keys are not an error.
+func (c *cache) Delete(ctx context.Context, key string) error {
+	if err := c.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache key %q: %w", key, err)
+	}
+
+	return nil
+}
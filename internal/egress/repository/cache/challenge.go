@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/redis/go-redis/v9"
+)
+
+type challenge struct {
+	client *redis.Client
+}
+
+func NewChallengeRepository(client *redis.Client) egress.ChallengeRepositoryPorts {
+	return &challenge{
+		client: client,
+	}
+}
+
+// Consume marks the challenge as used, returning false when it was already used
+func (c *challenge) Consume(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, "challenge:used:"+id, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume challenge %q: %w", id, err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes one token atomically.
+// Redis TIME is used so every replica shares the same clock.
+//
+//	KEYS[1] bucket key
+//	ARGV[1] capacity
+//	ARGV[2] refill rate in tokens per millisecond
+//	ARGV[3] key ttl in milliseconds
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+
+return {allowed, tostring(tokens)}
+`)
+
+type rateLimit struct {
+	client *redis.Client
+}
+
+func NewRateLimitRepository(client *redis.Client) egress.RateLimitRepositoryPorts {
+	return &rateLimit{
+		client: client,
+	}
+}
+
+// Take consumes one token from the bucket identified by key.
+func (r *rateLimit) Take(ctx context.Context, key string, rule *models.RateLimitRule) (*models.RateLimitResult, error) {
+	perMs := float64(rule.Rate) / float64(rule.Interval.Milliseconds())
+	fillTime := time.Duration(float64(rule.Capacity)/perMs) * time.Millisecond
+
+	values, err := tokenBucketScript.Run(ctx, r.client, []string{key},
+		rule.Capacity,
+		strconv.FormatFloat(perMs, 'f', -1, 64),
+		fillTime.Milliseconds()+1000,
+	).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run token bucket for key %q: %w", key, err)
+	}
+
+	if len(values) != 2 {
+		return nil, fmt.Errorf("unexpected token bucket reply for key %q: %v", key, values)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token count for key %q: %w", key, err)
+	}
+
+	result := &models.RateLimitResult{
+		Allowed:   allowed == 1,
+		Limit:     rule.Capacity,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     msToDuration((float64(rule.Capacity) - tokens) / perMs),
+	}
+
+	if !result.Allowed {
+		result.RetryAfter = msToDuration((1 - tokens) / perMs)
+	}
+
+	return result, nil
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/redis/go-redis/v9"
+)
+
+type replay struct {
+	client *redis.Client
+}
+
+func NewReplayRepository(client *redis.Client) egress.ReplayRepositoryPorts {
+	return &replay{
+		client: client,
+	}
+}
+
+// Consume marks the id as seen within the scope, returning false when it was seen before
+func (r *replay) Consume(ctx context.Context, scope, id string, ttl time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(ctx, fmt.Sprintf("replay:%s:%s", scope, id), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record %s %q: %w", scope, id, err)
+	}
+	return ok, nil
+}
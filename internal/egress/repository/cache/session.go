@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// session keeps sessions in Redis for the per request lookup. Every write also goes to the
+// fallback store, which answers whenever Redis misses or is unavailable.
+type session struct {
+	prefix   string
+	client   *redis.Client
+	fallback egress.SessionRepositoryPorts
+}
+
+// cachedSession keeps the fields the API response hides
+type cachedSession struct {
+	models.Session
+	DeviceHash  string        `json:"device_hash"`
+	RefreshHash string        `json:"refresh_hash"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+}
+
+func NewSessionRepository(prefix string, client *redis.Client, fallback egress.SessionRepositoryPorts) egress.SessionRepositoryPorts {
+	return &session{
+		prefix:   prefix,
+		client:   client,
+		fallback: fallback,
+	}
+}
+
+func (s *session) key(id string) string {
+	return fmt.Sprintf("%s:%s", s.prefix, id)
+}
+
+func (s *session) Add(ctx context.Context, session *models.Session) error {
+	if err := s.fallback.Add(ctx, session); err != nil {
+		return err
+	}
+
+	// The fallback already holds the session, a cache failure only costs a slower lookup
+	_ = s.set(ctx, session)
+	return nil
+}
+
+func (s *session) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	if session, err := s.get(ctx, id); err == nil {
+		return session, nil
+	}
+
+	session, err := s.fallback.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.set(ctx, session)
+	return session, nil
+}
+
+func (s *session) GetByUserID(ctx context.Context, userID int) ([]models.Session, error) {
+	return s.fallback.GetByUserID(ctx, userID)
+}
+
+func (s *session) Touch(ctx context.Context, id string, lastSeenAt time.Time) error {
+	if err := s.fallback.Touch(ctx, id, lastSeenAt); err != nil {
+		return err
+	}
+
+	return s.patch(ctx, id, func(session *models.Session) {
+		session.LastSeenAt = lastSeenAt
+	})
+}
+
+func (s *session) RotateRefreshHash(ctx context.Context, id, currentHash, refreshHash string) error {
+	if err := s.fallback.RotateRefreshHash(ctx, id, currentHash, refreshHash); err != nil {
+		return err
+	}
+
+	return s.patch(ctx, id, func(session *models.Session) {
+		session.RefreshHash = refreshHash
+	})
+}
+
+func (s *session) SetAuthentication(ctx context.Context, id string, acr constants.Acr, amr []string, authTime time.Time) error {
+	if err := s.fallback.SetAuthentication(ctx, id, acr, amr, authTime); err != nil {
+		return err
+	}
+
+	return s.patch(ctx, id, func(session *models.Session) {
+		session.Acr = acr
+		session.Amr = amr
+		session.AuthTime = authTime
+	})
+}
+
+func (s *session) AddClient(ctx context.Context, id, clientID string) error {
+	if err := s.fallback.AddClient(ctx, id, clientID); err != nil {
+		return err
+	}
+
+	return s.patch(ctx, id, func(session *models.Session) {
+		for _, existing := range session.Clients {
+			if existing == clientID {
+				return
+			}
+		}
+		session.Clients = append(session.Clients, clientID)
+	})
+}
+
+func (s *session) Delete(ctx context.Context, id string, userID int) error {
+	if err := s.fallback.Delete(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if err := s.client.Del(ctx, s.key(id)).Err(); err != nil {
+		return fmt.Errorf("failed to delete session %q: %w", id, err)
+	}
+	return nil
+}
+
+func (s *session) DeleteByUserID(ctx context.Context, userID int, exceptID string) error {
+	sessions, err := s.fallback.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.fallback.DeleteByUserID(ctx, userID, exceptID); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ID != exceptID {
+			keys = append(keys, s.key(session.ID))
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete sessions of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// patch updates the cached copy, a copy that cannot be updated is dropped so the next read reloads it
+func (s *session) patch(ctx context.Context, id string, update func(session *models.Session)) error {
+	session, err := s.get(ctx, id)
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	if err == nil {
+		update(session)
+		if err := s.set(ctx, session); err == nil {
+			return nil
+		}
+	}
+
+	if err := s.client.Del(ctx, s.key(id)).Err(); err != nil {
+		return fmt.Errorf("failed to drop cached session %q: %w", id, err)
+	}
+	return nil
+}
+
+func (s *session) get(ctx context.Context, id string) (*models.Session, error) {
+	result, err := s.client.Get(ctx, s.key(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var cached cachedSession
+	if err := json.Unmarshal([]byte(result), &cached); err != nil {
+		return nil, err
+	}
+
+	session := cached.Session
+	session.DeviceHash = cached.DeviceHash
+	session.RefreshHash = cached.RefreshHash
+	session.IdleTimeout = cached.IdleTimeout
+	return &session, nil
+}
+
+func (s *session) set(ctx context.Context, session *models.Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return utils.ErrInvalidSession
+	}
+
+	data, err := json.Marshal(&cachedSession{
+		Session:     *session,
+		DeviceHash:  session.DeviceHash,
+		RefreshHash: session.RefreshHash,
+		IdleTimeout: session.IdleTimeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Set(ctx, s.key(session.ID), data, ttl).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	return nil
+}
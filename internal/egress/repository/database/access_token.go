@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type accessToken struct {
+	client *gorm.DB
+}
+
+func NewAccessTokenRepository(client *gorm.DB) egress.AccessTokenRepositoryPorts {
+	return &accessToken{
+		client: client,
+	}
+}
+
+func (r *accessToken) Add(ctx context.Context, token *models.AccessToken) error {
+	return r.client.WithContext(ctx).Create(token).Error
+}
+
+func (r *accessToken) GetByID(ctx context.Context, id string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	err := r.client.WithContext(ctx).Where("id = ?", id).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &token, err
+}
+
+func (r *accessToken) GetByUserID(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	err := r.client.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *accessToken) Touch(ctx context.Context, id string, lastUsedAt time.Time, ip string) error {
+	return r.client.WithContext(ctx).Model(&models.AccessToken{}).Where("id = ?", id).Updates(map[string]any{
+		"last_used_at": lastUsedAt,
+		"last_used_ip": ip,
+	}).Error
+}
+
+func (r *accessToken) Revoke(ctx context.Context, id string, userID int, revokedAt time.Time) error {
+	result := r.client.WithContext(ctx).Model(&models.AccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+// RevokeByUserID revokes every token of the user that is not revoked yet
+func (r *accessToken) RevokeByUserID(ctx context.Context, userID int, revokedAt time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.AccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type certificateBinding struct {
+	client *gorm.DB
+}
+
+func NewCertificateBindingRepository(db *gorm.DB) egress.CertificateBindingRepositoryPorts {
+	return &certificateBinding{
+		client: db,
+	}
+}
+
+func (r *certificateBinding) Add(ctx context.Context, binding *models.CertificateBinding) error {
+	err := r.client.WithContext(ctx).Create(binding).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return utils.ErrDuplicate
+	}
+	return err
+}
+
+func (r *certificateBinding) GetByMatch(ctx context.Context, match constants.CertificateMatch, value string) (*models.CertificateBinding, error) {
+	var binding models.CertificateBinding
+	err := r.client.WithContext(ctx).Where("match = ? AND value = ?", match, value).First(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &binding, err
+}
+
+func (r *certificateBinding) List(ctx context.Context) ([]models.CertificateBinding, error) {
+	var bindings []models.CertificateBinding
+	err := r.client.WithContext(ctx).Order("created_at").Find(&bindings).Error
+	return bindings, err
+}
+
+func (r *certificateBinding) DeleteByID(ctx context.Context, id string) error {
+	result := r.client.WithContext(ctx).Where("id = ?", id).Delete(&models.CertificateBinding{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
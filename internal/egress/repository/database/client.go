@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type client struct {
+	client *gorm.DB
+}
+
+func NewClientRepository(db *gorm.DB) egress.ClientRepositoryPorts {
+	return &client{
+		client: db,
+	}
+}
+
+func (r *client) Add(ctx context.Context, client *models.Client) error {
+	err := r.client.WithContext(ctx).Create(client).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return utils.ErrDuplicate
+	}
+	return err
+}
+
+func (r *client) GetByID(ctx context.Context, id string) (*models.Client, error) {
+	var client models.Client
+	err := r.client.WithContext(ctx).Where("id = ?", id).First(&client).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &client, err
+}
+
+func (r *client) List(ctx context.Context) ([]models.Client, error) {
+	var clients []models.Client
+	err := r.client.WithContext(ctx).Order("created_at").Find(&clients).Error
+	return clients, err
+}
+
+func (r *client) DeleteByID(ctx context.Context, id string) error {
+	result := r.client.WithContext(ctx).Where("id = ?", id).Delete(&models.Client{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *client) UpdateClaimMapping(ctx context.Context, id string, mapping models.ClaimMapping) error {
+	// Updates with a struct goes through the JSON serializer, Select also writes an empty mapping
+	result := r.client.WithContext(ctx).Model(&models.Client{ID: id}).Select("claim_mapping").Updates(&models.Client{ClaimMapping: mapping})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
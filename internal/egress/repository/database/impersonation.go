@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"gorm.io/gorm"
+)
+
+type impersonation struct {
+	client *gorm.DB
+}
+
+func NewImpersonationRepository(db *gorm.DB) egress.ImpersonationRepositoryPorts {
+	return &impersonation{
+		client: db,
+	}
+}
+
+func (r *impersonation) Add(ctx context.Context, impersonation *models.Impersonation) error {
+	return r.client.WithContext(ctx).Create(impersonation).Error
+}
+
+// List returns the latest impersonations first
+func (r *impersonation) List(ctx context.Context, limit int) ([]models.Impersonation, error) {
+	var impersonations []models.Impersonation
+	err := r.client.WithContext(ctx).Order("created_at DESC").Limit(limit).Find(&impersonations).Error
+	return impersonations, err
+}
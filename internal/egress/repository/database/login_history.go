@@ -38,8 +38,9 @@ func (l *loginHistory) GetByID(ctx context.Context, id int) (*models.LoginHistor
 	return &loginHistory, err
 }
 
+// GetByIDAndLoginAt returns the user's logins since loginAt, newest first
 func (l *loginHistory) GetByIDAndLoginAt(ctx context.Context, id int, loginAt time.Time) ([]models.LoginHistory, error) {
 	var loginHistory []models.LoginHistory
-	err := l.client.WithContext(ctx).First(&loginHistory, id).Error
+	err := l.client.WithContext(ctx).Where("user_id = ? AND login_at >= ?", id, loginAt).Order("login_at DESC").Find(&loginHistory).Error
 	return loginHistory, err
 }
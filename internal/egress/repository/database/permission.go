@@ -22,8 +22,8 @@ func NewPermissionRepository(client *gorm.DB) egress.PermissionRepositoryPorts {
 
 func (r *permission) Add(ctx context.Context, permission *ingressModel.Permission) error {
 	err := r.client.WithContext(ctx).Create(permission).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return utils.ErrDocumentNotFound
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return utils.ErrDuplicate
 	}
 	return err
 }
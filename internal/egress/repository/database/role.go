@@ -23,8 +23,8 @@ func NewRoleRepository(client *gorm.DB) egress.RoleRepositoryPorts {
 
 func (r *role) Add(ctx context.Context, role *models.Role) error {
 	err := r.client.WithContext(ctx).Create(role).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return utils.ErrDocumentNotFound
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return utils.ErrDuplicate
 	}
 	return err
 }
@@ -47,13 +47,29 @@ func (r *role) GetByIDs(ctx context.Context, ids []constants.Roles) ([]models.Ro
 	return role, err
 }
 
+// Update writes the editable fields of the role, the ID, system flag and creation stay as stored
+func (r *role) Update(ctx context.Context, role *models.Role) error {
+	result := r.client.WithContext(ctx).Model(&models.Role{}).Where("id = ?", role.ID).
+		Select("description", "permissions", "parents", "status", "idle_timeout", "absolute_lifetime", "max_sessions", "session_policy", "updated_by", "updated_at").
+		Updates(role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
 func (r *role) DeleteByID(ctx context.Context, id constants.Roles) error {
-	var role models.Role
-	err := r.client.WithContext(ctx).Delete(&role, id).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	result := r.client.WithContext(ctx).Where("id = ?", id).Delete(&models.Role{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
 		return utils.ErrDocumentNotFound
 	}
-	return err
+	return nil
 }
 
 func (r *role) GetRolesWithoutPagination(ctx context.Context) ([]models.Role, error) {
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type serviceAccount struct {
+	client *gorm.DB
+}
+
+func NewServiceAccountRepository(client *gorm.DB) egress.ServiceAccountRepositoryPorts {
+	return &serviceAccount{
+		client: client,
+	}
+}
+
+func (r *serviceAccount) Add(ctx context.Context, account *models.ServiceAccount) error {
+	return r.client.WithContext(ctx).Create(account).Error
+}
+
+func (r *serviceAccount) GetByID(ctx context.Context, id string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.client.WithContext(ctx).Where("id = ?", id).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &account, err
+}
+
+func (r *serviceAccount) List(ctx context.Context) ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := r.client.WithContext(ctx).Order("team, name").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *serviceAccount) UpdateRoles(ctx context.Context, id string, roles []string, updatedBy int) error {
+	result := r.client.WithContext(ctx).Model(&models.ServiceAccount{}).Where("id = ?", id).Updates(map[string]any{
+		"roles":      roles,
+		"updated_by": updatedBy,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *serviceAccount) UpdateStatus(ctx context.Context, id string, status constants.Status, updatedBy int) error {
+	result := r.client.WithContext(ctx).Model(&models.ServiceAccount{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     status,
+		"updated_by": updatedBy,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *serviceAccount) AddKey(ctx context.Context, key *models.ServiceAccountKey) error {
+	return r.client.WithContext(ctx).Create(key).Error
+}
+
+func (r *serviceAccount) GetKey(ctx context.Context, accountID, keyID string) (*models.ServiceAccountKey, error) {
+	var key models.ServiceAccountKey
+	err := r.client.WithContext(ctx).Where("id = ? AND service_account_id = ?", keyID, accountID).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &key, err
+}
+
+func (r *serviceAccount) GetKeys(ctx context.Context, accountID string) ([]models.ServiceAccountKey, error) {
+	var keys []models.ServiceAccountKey
+	err := r.client.WithContext(ctx).Where("service_account_id = ?", accountID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *serviceAccount) TouchKey(ctx context.Context, keyID string, lastUsedAt time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.ServiceAccountKey{}).Where("id = ?", keyID).Update("last_used_at", lastUsedAt).Error
+}
+
+// RevokeKeys revokes the key, or every key of the account when keyID is empty
+func (r *serviceAccount) RevokeKeys(ctx context.Context, accountID, keyID string, revokedAt time.Time) error {
+	query := r.client.WithContext(ctx).Model(&models.ServiceAccountKey{}).Where("service_account_id = ? AND revoked_at IS NULL", accountID)
+	if keyID != "" {
+		query = query.Where("id = ?", keyID)
+	}
+
+	result := query.Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if keyID != "" && result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *serviceAccount) AddAudit(ctx context.Context, entry *models.ServiceAccountAudit) error {
+	return r.client.WithContext(ctx).Create(entry).Error
+}
+
+func (r *serviceAccount) GetAudit(ctx context.Context, accountID string, limit int) ([]models.ServiceAccountAudit, error) {
+	var entries []models.ServiceAccountAudit
+	err := r.client.WithContext(ctx).Where("service_account_id = ?", accountID).Order("created_at DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
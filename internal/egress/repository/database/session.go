@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type session struct {
+	client *gorm.DB
+}
+
+func NewSessionRepository(client *gorm.DB) egress.SessionRepositoryPorts {
+	return &session{
+		client: client,
+	}
+}
+
+func (r *session) Add(ctx context.Context, session *models.Session) error {
+	return r.client.WithContext(ctx).Create(session).Error
+}
+
+func (r *session) GetByID(ctx context.Context, id string) (*models.Session, error) {
+	var session models.Session
+	err := r.client.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &session, err
+}
+
+func (r *session) GetByUserID(ctx context.Context, userID int) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.client.WithContext(ctx).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *session) Touch(ctx context.Context, id string, lastSeenAt time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", lastSeenAt).Error
+}
+
+// RotateRefreshHash replaces the refresh hash only while it is still currentHash, so of two
+// refreshes with the same token only one succeeds
+func (r *session) RotateRefreshHash(ctx context.Context, id, currentHash, refreshHash string) error {
+	result := r.client.WithContext(ctx).Model(&models.Session{}).Where("id = ? AND refresh_hash = ?", id, currentHash).Update("refresh_hash", refreshHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *session) SetAuthentication(ctx context.Context, id string, acr constants.Acr, amr []string, authTime time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Updates(map[string]any{
+		"acr":       acr,
+		"amr":       amr,
+		"auth_time": authTime,
+	}).Error
+}
+
+// AddClient records that the client signed in through the session, once per client
+func (r *session) AddClient(ctx context.Context, id, clientID string) error {
+	return r.client.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND NOT (? = ANY(COALESCE(clients, '{}')))", id, clientID).
+		Update("clients", gorm.Expr("array_append(clients, ?)", clientID)).Error
+}
+
+func (r *session) Delete(ctx context.Context, id string, userID int) error {
+	result := r.client.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+// DeleteByUserID removes every session of the user except exceptID, which may be empty
+func (r *session) DeleteByUserID(ctx context.Context, userID int, exceptID string) error {
+	query := r.client.WithContext(ctx).Where("user_id = ?", userID)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return query.Delete(&models.Session{}).Error
+}
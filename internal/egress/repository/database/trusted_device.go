@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"gorm.io/gorm"
+)
+
+type trustedDevice struct {
+	client *gorm.DB
+}
+
+func NewTrustedDeviceRepository(client *gorm.DB) egress.TrustedDeviceRepositoryPorts {
+	return &trustedDevice{
+		client: client,
+	}
+}
+
+func (r *trustedDevice) Add(ctx context.Context, device *models.TrustedDevice) error {
+	return r.client.WithContext(ctx).Create(device).Error
+}
+
+func (r *trustedDevice) GetByID(ctx context.Context, id string) (*models.TrustedDevice, error) {
+	var device models.TrustedDevice
+	err := r.client.WithContext(ctx).Where("id = ?", id).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrDocumentNotFound
+	}
+	return &device, err
+}
+
+func (r *trustedDevice) GetByUserID(ctx context.Context, userID int) ([]models.TrustedDevice, error) {
+	var devices []models.TrustedDevice
+	err := r.client.WithContext(ctx).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("last_used_at DESC").Find(&devices).Error
+	return devices, err
+}
+
+func (r *trustedDevice) Touch(ctx context.Context, id string, lastUsedAt time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.TrustedDevice{}).Where("id = ?", id).Update("last_used_at", lastUsedAt).Error
+}
+
+func (r *trustedDevice) DeleteByID(ctx context.Context, id string, userID int) error {
+	result := r.client.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.TrustedDevice{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrDocumentNotFound
+	}
+	return nil
+}
+
+func (r *trustedDevice) DeleteByUserID(ctx context.Context, userID int) error {
+	return r.client.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.TrustedDevice{}).Error
+}
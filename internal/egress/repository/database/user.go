@@ -40,9 +40,9 @@ func (r *user) GetByID(ctx context.Context, id int) (*models.User, error) {
 
 func (r *user) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.client.WithContext(ctx).First(&user, email).Error
+	err := r.client.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, nil
+		return nil, utils.ErrDocumentNotFound
 	}
 	return &user, err
 }
@@ -50,3 +50,10 @@ func (r *user) GetByEmail(ctx context.Context, email string) (*models.User, erro
 func (r *user) LockByID(ctx context.Context, id int, lockout_until time.Time) error {
 	return r.client.WithContext(ctx).Where("id=?", id).Update("lockout_until", lockout_until).Error
 }
+
+func (r *user) UpdatePassword(ctx context.Context, id int, password string, changedAt time.Time) error {
+	return r.client.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"password":            password,
+		"password_changed_at": changedAt,
+	}).Error
+}
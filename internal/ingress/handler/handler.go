@@ -29,6 +29,7 @@ func NewHandler(middlewarePorts ingress.MiddlewarePorts) (fasthttp.RequestHandle
 	newHandler := chainMiddleware(r.Handler,
 		middlewarePorts.RequestID,
 		middlewarePorts.PanicRecover,
+		middlewarePorts.RateLimit,
 	)
 
 	return newHandler, &handler{
@@ -47,6 +48,15 @@ func (h *handler) SetAuthHandler(authService ingress.AuthServicePorts) {
 	userGroup := h.route.Group("/api/v1/auth")
 	userGroup.GET("/session", authService.Session)
 	userGroup.POST("/signin", h.middlewarePorts.Authorization(constants.PrmSignin)(authService.Signin))
+	userGroup.GET("/challenge", h.middlewarePorts.Authorization(constants.PrmSignin)(authService.Challenge))
+	userGroup.POST("/otp", h.middlewarePorts.Authorization(constants.PrmOtpSend)(authService.Otp))
+	userGroup.POST("/verify", h.middlewarePorts.Authorization(constants.PrmOtpVerify)(authService.Verify))
+	userGroup.PUT("/password", h.middlewarePorts.Authorization(constants.PrmChangePassword)(authService.ChangePassword))
+	userGroup.POST("/step-up", h.middlewarePorts.Authorization(constants.PrmStepUp)(authService.StepUp))
+	userGroup.POST("/refresh", h.middlewarePorts.Authorization(constants.PrmTokenRefresh)(authService.Refresh))
+	userGroup.POST("/logout", h.middlewarePorts.Authorization(constants.PrmLogout)(authService.Logout))
+	userGroup.POST("/logout/all", h.middlewarePorts.Authorization(constants.PrmLogout)(authService.LogoutAll))
+	userGroup.POST("/users/{id}/logout", h.middlewarePorts.Authorization(constants.PrmForceLogout)(authService.ForceLogout))
 	userGroup.POST("/signup", h.middlewarePorts.Authorization(constants.PrmSignup)(authService.Signin))
 }
 
@@ -61,18 +71,89 @@ func (h *handler) SetUserHandler(userService ingress.UserServicePorts) {
 
 func (h *handler) SetRoleHandler(roleService ingress.RoleServicePorts) {
 	roleGroup := h.route.Group("/api/v1/roles")
-	roleGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListRoles)(roleService.List))            // List
-	roleGroup.GET("/{id}", h.middlewarePorts.Authorization(constants.PrmInfoRole)(roleService.Info))         // Info
-	roleGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddRoles)(roleService.Add))             // Add
-	roleGroup.PUT("/{id}", h.middlewarePorts.Authorization(constants.PrmEditRoles)(roleService.Update))      // Update
-	roleGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeleteRoles)(roleService.Delete)) // Delete
+	roleGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListRoles)(roleService.List))                       // List
+	roleGroup.GET("/{id}", h.middlewarePorts.Authorization(constants.PrmInfoRole)(roleService.Info))                    // Info
+	roleGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddRoles)(roleService.Add))                        // Add
+	roleGroup.PUT("/{id}", h.middlewarePorts.Authorization(constants.PrmEditRoles)(roleService.Update))                 // Update
+	roleGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeleteRoles)(roleService.Delete))            // Delete
+	roleGroup.GET("/{id}/permissions", h.middlewarePorts.Authorization(constants.PrmInfoRole)(roleService.Permissions)) // Effective permissions
 }
 
 func (h *handler) SetPermissionHandler(permissionsService ingress.PermissionServicePorts) {
 	permissionGroup := h.route.Group("/api/v1/permissions")
 	permissionGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListPermissions)(permissionsService.List))            // List
+	permissionGroup.GET("/index", h.middlewarePorts.Authorization(constants.PrmListPermissions)(permissionsService.Index))      // Index of token bitmaps
 	permissionGroup.GET("/{id}", h.middlewarePorts.Authorization(constants.PrmInfoPermission)(permissionsService.Info))         // Info
 	permissionGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddPermissions)(permissionsService.Add))             // Add
 	permissionGroup.PUT("/{id}", h.middlewarePorts.Authorization(constants.PrmEditPermissions)(permissionsService.Update))      // Update
 	permissionGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeletePermissions)(permissionsService.Delete)) // Delete
 }
+
+func (h *handler) SetSecurityHandler(abuseService ingress.AbuseServicePorts) {
+	securityGroup := h.route.Group("/api/v1/security")
+	securityGroup.GET("/blocked", h.middlewarePorts.Authorization(constants.PrmListBlockedSources)(abuseService.Blocked))        // List blocked sources
+	securityGroup.DELETE("/blocked/{source}", h.middlewarePorts.Authorization(constants.PrmUnblockSource)(abuseService.Unblock)) // Unblock
+}
+
+func (h *handler) SetDeviceHandler(deviceService ingress.DeviceServicePorts) {
+	deviceGroup := h.route.Group("/api/v1/auth/devices")
+	deviceGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListDevices)(deviceService.List))           // List
+	deviceGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmRevokeDevice)(deviceService.Revoke)) // Revoke
+}
+
+func (h *handler) SetSessionHandler(sessionService ingress.SessionServicePorts) {
+	sessionGroup := h.route.Group("/api/v1/auth/sessions")
+	sessionGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListSessions)(sessionService.List))             // List
+	sessionGroup.DELETE("/", h.middlewarePorts.Authorization(constants.PrmRevokeSession)(sessionService.RevokeOthers)) // Revoke all others
+	sessionGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmRevokeSession)(sessionService.Revoke))   // Revoke
+}
+
+func (h *handler) SetClientHandler(clientService ingress.ClientServicePorts) {
+	clientGroup := h.route.Group("/api/v1/clients")
+	clientGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListClients)(clientService.List))           // List
+	clientGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddClient)(clientService.Add))             // Add
+	clientGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeleteClient)(clientService.Delete)) // Delete
+	clientGroup.PUT("/{id}/claims", h.middlewarePorts.Authorization(constants.PrmEditClient)(clientService.UpdateClaims))
+}
+
+func (h *handler) SetAccessTokenHandler(accessTokenService ingress.AccessTokenServicePorts) {
+	tokenGroup := h.route.Group("/api/v1/auth/tokens")
+	tokenGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListAccessTokens)(accessTokenService.List))           // List
+	tokenGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddAccessToken)(accessTokenService.Add))             // Add
+	tokenGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmRevokeAccessToken)(accessTokenService.Revoke)) // Revoke
+}
+
+func (h *handler) SetServiceAccountHandler(serviceAccountService ingress.ServiceAccountServicePorts) {
+	accountGroup := h.route.Group("/api/v1/service-accounts")
+	accountGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListServiceAccounts)(serviceAccountService.List))                       // List
+	accountGroup.GET("/{id}", h.middlewarePorts.Authorization(constants.PrmListServiceAccounts)(serviceAccountService.Info))                   // Info
+	accountGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddServiceAccount)(serviceAccountService.Add))                         // Add
+	accountGroup.PUT("/{id}/roles", h.middlewarePorts.Authorization(constants.PrmEditServiceAccount)(serviceAccountService.UpdateRoles))       // Update roles
+	accountGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeleteServiceAccount)(serviceAccountService.Disable))            // Disable
+	accountGroup.POST("/{id}/keys", h.middlewarePorts.Authorization(constants.PrmEditServiceAccount)(serviceAccountService.AddKey))            // Add key
+	accountGroup.DELETE("/{id}/keys/{kid}", h.middlewarePorts.Authorization(constants.PrmEditServiceAccount)(serviceAccountService.RevokeKey)) // Revoke key
+	accountGroup.GET("/{id}/audit", h.middlewarePorts.Authorization(constants.PrmListServiceAccounts)(serviceAccountService.Audit))            // Audit trail
+}
+
+func (h *handler) SetCertificateHandler(mtlsService ingress.MtlsServicePorts) {
+	certificateGroup := h.route.Group("/api/v1/certificates")
+	certificateGroup.GET("/", h.middlewarePorts.Authorization(constants.PrmListCertificateBindings)(mtlsService.List))           // List
+	certificateGroup.POST("/", h.middlewarePorts.Authorization(constants.PrmAddCertificateBinding)(mtlsService.Add))             // Add
+	certificateGroup.DELETE("/{id}", h.middlewarePorts.Authorization(constants.PrmDeleteCertificateBinding)(mtlsService.Delete)) // Delete
+}
+
+// SetOAuthHandler registers the authorization endpoints, which authenticate through the SSO
+// cookie and client credentials instead of a bearer token. Only approving a device needs one.
+func (h *handler) SetOAuthHandler(oauthService ingress.OAuthServicePorts) {
+	oauthGroup := h.route.Group("/api/v1/oauth")
+	oauthGroup.GET("/authorize", oauthService.Authorize)
+	oauthGroup.POST("/token", oauthService.Token)
+	oauthGroup.POST("/introspect", oauthService.Introspect)
+	oauthGroup.POST("/revoke", oauthService.Revoke)
+	oauthGroup.GET("/jwks", oauthService.Jwks) // Keys of the logout tokens
+	oauthGroup.GET("/logout", oauthService.Logout)
+	oauthGroup.POST("/device_authorization", oauthService.DeviceAuthorization)
+	oauthGroup.GET("/device", h.middlewarePorts.Authorization(constants.PrmApproveDevice)(oauthService.DeviceLookup))  // Request shown before approval
+	oauthGroup.POST("/device", h.middlewarePorts.Authorization(constants.PrmApproveDevice)(oauthService.DeviceVerify)) // Approve or deny
+	oauthGroup.GET("/impersonations", h.middlewarePorts.Authorization(constants.PrmListImpersonations)(oauthService.Impersonations))
+}
@@ -1,12 +1,18 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
 	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
@@ -16,16 +22,26 @@ import (
 )
 
 type middleware struct {
-	config       *models.Config
-	logger       ports.Logger
-	tokenService ingress.TokenServicePorts
+	config             *models.Config
+	logger             ports.Logger
+	tokenService       ingress.TokenServicePorts
+	sessionService     ingress.SessionServicePorts
+	accessTokenService ingress.AccessTokenServicePorts
+	mtlsService        ingress.MtlsServicePorts
+	dpopService        ingress.DpopServicePorts
+	egressRepository   egress.Repository
 }
 
-func NewMiddleWare(config *models.Config, logger ports.Logger, tokenService ingress.TokenServicePorts) ingress.MiddlewarePorts {
+func NewMiddleWare(config *models.Config, logger ports.Logger, tokenService ingress.TokenServicePorts, sessionService ingress.SessionServicePorts, accessTokenService ingress.AccessTokenServicePorts, mtlsService ingress.MtlsServicePorts, dpopService ingress.DpopServicePorts, egressRepository egress.Repository) ingress.MiddlewarePorts {
 	return &middleware{
-		config:       config,
-		logger:       logger,
-		tokenService: tokenService,
+		config:             config,
+		logger:             logger,
+		tokenService:       tokenService,
+		sessionService:     sessionService,
+		accessTokenService: accessTokenService,
+		mtlsService:        mtlsService,
+		dpopService:        dpopService,
+		egressRepository:   egressRepository,
 	}
 }
 
@@ -45,8 +61,8 @@ func (m *middleware) Authorization(requiredPermission string) func(next fasthttp
 	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 		return func(ctx *fasthttp.RequestCtx) {
 
-			authHeader := string(ctx.Request.Header.Peek(constants.Authorization))
-			if authHeader == "" || !strings.HasPrefix(authHeader, constants.AuthType) {
+			token, fromCookie := m.bearerToken(ctx)
+			if token == "" {
 				reqID := utils.GetField(ctx, constants.CtxRequestID)
 				m.logger.Info("missing or invalid authorization header", zap.String("requestID", reqID))
 
@@ -59,8 +75,118 @@ func (m *middleware) Authorization(requiredPermission string) func(next fasthttp
 				return
 			}
 
-			token := strings.TrimPrefix(authHeader, constants.AuthType)
-			if !m.tokenService.HavePermission(token, requiredPermission) {
+			// Personal access tokens are looked up instead of verified, they have no session. Opaque
+			// tokens are looked up as well but are otherwise handled like JWTs.
+			isAccessToken := strings.HasPrefix(token, constants.AccessTokenPrefix)
+
+			var (
+				tokenInfo *models.Token
+				err       error
+			)
+			if isAccessToken {
+				tokenInfo, err = m.accessTokenService.Authenticate(ctx, token, utils.ClientIP(ctx, m.config.App.Server.TrustProxy, m.config.App.Server.TrustedProxies))
+			} else {
+				tokenInfo, err = m.tokenService.ResolveToken(ctx, token)
+			}
+
+			if err != nil && !errors.Is(err, utils.ErrInvalidAccessToken) && !errors.Is(err, utils.ErrInvalidToken) {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Error("access token lookup failed", zap.String("requestID", reqID), zap.Error(err))
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(fasthttp.StatusServiceUnavailable).
+					SetError(&models.Error{
+						Code:    "ME-AN-5",
+						Message: "Unable to verify token. Please try again later.",
+					}).Send(ctx)
+				return
+			}
+
+			if err != nil {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Info("invalid token", zap.String("requestID", reqID), zap.Error(err))
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(fasthttp.StatusUnauthorized).
+					SetError(&models.Error{
+						Code:    "ME-AN-3",
+						Message: "Invalid or expired token",
+					}).Send(ctx)
+				return
+			}
+
+			// A certificate bound token is only accepted with the certificate it was issued to
+			if !m.validCertificateBinding(ctx, tokenInfo) {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Info("certificate binding mismatch", zap.String("requestID", reqID))
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(fasthttp.StatusUnauthorized).
+					SetError(&models.Error{
+						Code:    "ME-AN-8",
+						Message: "Token is bound to a client certificate that was not presented",
+					}).Send(ctx)
+				return
+			}
+
+			// A DPoP bound token needs a fresh proof signed with its key on every request
+			if err := m.validDpopBinding(ctx, token, tokenInfo); err != nil {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+
+				statusCode, code, msg := fasthttp.StatusUnauthorized, "ME-AN-9", "Invalid or missing DPoP proof"
+				if !errors.Is(err, utils.ErrInvalidDpopProof) {
+					m.logger.Error("DPoP proof verification failed", zap.String("requestID", reqID), zap.Error(err))
+					statusCode, code, msg = fasthttp.StatusServiceUnavailable, "ME-AN-5", "Unable to verify token. Please try again later."
+				} else {
+					m.logger.Info("invalid DPoP proof", zap.String("requestID", reqID), zap.Error(err))
+					ctx.Response.Header.Set(constants.WWWAuthenticate.String(), fmt.Sprintf(`DPoP error="%s", algs="ES256 ES384 RS256 PS256 EdDSA"`, constants.OAuthInvalidDpopProof))
+				}
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(statusCode).
+					SetError(&models.Error{
+						Code:    code,
+						Message: msg,
+					}).Send(ctx)
+				return
+			}
+
+			// Cookies ride along with cross site requests, so state changes must prove same origin
+			if fromCookie && !isSafeMethod(ctx) && !m.validCsrf(ctx, tokenInfo) {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Info("invalid csrf token", zap.String("requestID", reqID), zap.ByteString("path", ctx.Path()))
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(fasthttp.StatusForbidden).
+					SetError(&models.Error{
+						Code:    "ME-AN-7",
+						Message: "Invalid or missing CSRF token",
+					}).Send(ctx)
+				return
+			}
+
+			// Signed out tokens and tokens bound to a revoked session are rejected right away
+			if _, err := m.validateSession(ctx, tokenInfo, isAccessToken); err != nil {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+
+				statusCode, code, msg := fasthttp.StatusUnauthorized, "ME-AN-4", "Session expired or revoked"
+				if !errors.Is(err, utils.ErrInvalidSession) {
+					m.logger.Error("session lookup failed", zap.String("requestID", reqID), zap.Error(err))
+					statusCode, code, msg = fasthttp.StatusServiceUnavailable, "ME-AN-5", "Unable to verify session. Please try again later."
+				} else {
+					m.logger.Info("invalid session", zap.String("requestID", reqID), zap.String("sessionID", tokenInfo.SessionID))
+				}
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(statusCode).
+					SetError(&models.Error{
+						Code:    code,
+						Message: msg,
+					}).Send(ctx)
+				return
+			}
+
+			if !tokenInfo.HasPermission(requiredPermission) {
 				reqID := utils.GetField(ctx, constants.CtxRequestID)
 				m.logger.Info("permission denied", zap.String("requestID", reqID), zap.String("requiredPermission", requiredPermission))
 
@@ -73,12 +199,154 @@ func (m *middleware) Authorization(requiredPermission string) func(next fasthttp
 				return
 			}
 
+			if tokenInfo.Act != nil {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Info("delegated request", zap.String("requestID", reqID), zap.Int("userID", tokenInfo.UserID), zap.String("actor", tokenInfo.Act.Subject), zap.ByteString("method", ctx.Method()), zap.ByteString("path", ctx.Path()))
+
+				if slices.Contains(constants.AdminWritePermissions, requiredPermission) {
+					response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+						SetStatusCode(fasthttp.StatusForbidden).
+						SetError(&models.Error{
+							Code:    "ME-AN-10",
+							Message: "Administrative changes are not allowed with a delegated token",
+						}).Send(ctx)
+					return
+				}
+			}
+
+			if rule, found := m.stepUpRule(requiredPermission); found && !stepUpSatisfied(tokenInfo, rule) {
+				reqID := utils.GetField(ctx, constants.CtxRequestID)
+				m.logger.Info("step-up required", zap.String("requestID", reqID), zap.String("requiredPermission", requiredPermission))
+
+				detail := &models.StepUpRequired{
+					AcrValues: rule.Acr,
+					MaxAge:    int(rule.MaxAge.Seconds()),
+				}
+
+				challenge := fmt.Sprintf(`Bearer error="%s", error_description="A stronger or more recent authentication is required", acr_values="%s"`, constants.ErrInsufficientUserAuthentication, rule.Acr)
+				if detail.MaxAge > 0 {
+					challenge += fmt.Sprintf(", max_age=%d", detail.MaxAge)
+				}
+				ctx.Response.Header.Set(constants.WWWAuthenticate.String(), challenge)
+
+				response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+					SetStatusCode(fasthttp.StatusUnauthorized).
+					SetError(&models.Error{
+						Code:    "ME-AN-6",
+						Message: constants.ErrInsufficientUserAuthentication,
+						Detail:  detail,
+					}).Send(ctx)
+				return
+			}
+
 			// All good — proceed to next handler
+			ctx.SetUserValue(constants.CtxTokenInfo, tokenInfo)
 			next(ctx)
 		}
 	}
 }
 
+// validCertificateBinding compares the cnf thumbprint of the token with the presented certificate
+func (m *middleware) validCertificateBinding(ctx *fasthttp.RequestCtx, tokenInfo *models.Token) bool {
+	if tokenInfo.Cnf == nil || tokenInfo.Cnf.X5tS256 == "" {
+		return true
+	}
+
+	cert := m.mtlsService.Certificate(ctx)
+	if cert == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(utils.CertThumbprint(cert)), []byte(tokenInfo.Cnf.X5tS256)) == 1
+}
+
+// validDpopBinding verifies the proof sent with a DPoP bound token, which must use the DPoP scheme
+func (m *middleware) validDpopBinding(ctx *fasthttp.RequestCtx, token string, tokenInfo *models.Token) error {
+	if tokenInfo.Cnf == nil || tokenInfo.Cnf.Jkt == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(string(ctx.Request.Header.Peek(constants.Authorization)), constants.DpopAuthType) {
+		return fmt.Errorf("%w: bound token sent without the DPoP scheme", utils.ErrInvalidDpopProof)
+	}
+
+	jkt, err := m.dpopService.VerifyProof(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(jkt), []byte(tokenInfo.Cnf.Jkt)) != 1 {
+		return fmt.Errorf("%w: proof signed with another key", utils.ErrInvalidDpopProof)
+	}
+	return nil
+}
+
+// validateSession skips personal access tokens, they are revoked one by one instead of by session
+func (m *middleware) validateSession(ctx *fasthttp.RequestCtx, tokenInfo *models.Token, isAccessToken bool) (*models.Session, error) {
+	if isAccessToken {
+		return nil, nil
+	}
+	return m.sessionService.Validate(ctx, tokenInfo)
+}
+
+// bearerToken returns the token of the Authorization header, falling back to the session cookie
+func (m *middleware) bearerToken(ctx *fasthttp.RequestCtx) (string, bool) {
+	authHeader := string(ctx.Request.Header.Peek(constants.Authorization))
+	if authHeader != "" {
+		if token, found := strings.CutPrefix(authHeader, constants.DpopAuthType); found {
+			return token, false
+		}
+		if !strings.HasPrefix(authHeader, constants.AuthType) {
+			return "", false
+		}
+		return strings.TrimPrefix(authHeader, constants.AuthType), false
+	}
+
+	if m.config.Cookie == nil || !m.config.Cookie.Enabled {
+		return "", false
+	}
+
+	token := string(ctx.Request.Header.Cookie(m.config.Cookie.AccessName))
+	return token, token != ""
+}
+
+// validCsrf checks the double submitted CSRF token, which must also be the one issued for the token
+func (m *middleware) validCsrf(ctx *fasthttp.RequestCtx, tokenInfo *models.Token) bool {
+	header := ctx.Request.Header.Peek(constants.XCsrfToken.String())
+	cookie := ctx.Request.Header.Cookie(m.config.Cookie.CsrfName)
+	if len(header) == 0 || subtle.ConstantTimeCompare(header, cookie) != 1 {
+		return false
+	}
+
+	expected := utils.CsrfToken(m.config.Cookie.SecretKey, tokenInfo.ID)
+	return subtle.ConstantTimeCompare(header, []byte(expected)) == 1
+}
+
+func isSafeMethod(ctx *fasthttp.RequestCtx) bool {
+	return ctx.IsGet() || ctx.IsHead() || ctx.IsOptions() || ctx.IsTrace()
+}
+
+func (m *middleware) stepUpRule(permission string) (models.StepUpRule, bool) {
+	if m.config.StepUp == nil || !m.config.StepUp.Enabled {
+		return models.StepUpRule{}, false
+	}
+
+	rule, found := m.config.StepUp.Rules[permission]
+	return rule, found
+}
+
+// stepUpSatisfied reports whether the token was authenticated strongly and recently enough
+func stepUpSatisfied(tokenInfo *models.Token, rule models.StepUpRule) bool {
+	if tokenInfo.Acr.Level() < rule.Acr.Level() {
+		return false
+	}
+
+	if rule.MaxAge > 0 && (tokenInfo.AuthTime == nil || time.Since(tokenInfo.AuthTime.Time) > rule.MaxAge) {
+		return false
+	}
+
+	return true
+}
+
 // PanicRecover handles panics and responds with a standard error message
 func (m *middleware) PanicRecover(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
@@ -0,0 +1,228 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/egress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/ports/ingress"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/services"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/egress/codec"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/middleware"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/bhupendra-dudhwal/sso-gateway/pkg/logger"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	actorID  = 1
+	targetID = 2
+)
+
+// An impersonation token rides the session of the staff member, it is accepted while that
+// session lives and rejected once it ends
+func TestAuthorizationImpersonationToken(t *testing.T) {
+	var (
+		config = &models.Config{
+			App:           &models.App{Server: &models.Server{}},
+			Jwt:           &models.Jwt{SecretKey: "test-secret", Issuer: "sso-gateway", LifeSpan: 15 * time.Minute},
+			Session:       &models.SessionStore{Enabled: true, TouchInterval: time.Hour},
+			TokenExchange: &models.TokenExchange{Enabled: true, ImpersonationLifeSpan: 10 * time.Minute},
+		}
+		log              = logger.NewLogger(&models.Logger{Level: "error"}, constants.Development)
+		sessions         = &sessionRepository{sessions: make(map[string]*models.Session)}
+		egressRepository = egress.Repository{
+			Cache:         &cacheRepository{values: make(map[string][]byte)},
+			Role:          &roleRepository{},
+			User:          &userRepository{users: map[int]*models.User{targetID: {ID: targetID, Role: "member", Status: constants.StatusActive, Permissions: []string{constants.PrmInfoUser}}}},
+			Session:       sessions,
+			Impersonation: &impersonationRepository{},
+			TokenCodec:    codec.NewJwt(config.Jwt.SecretKey),
+		}
+		ingressRepository = ingress.Repository{
+			Token:   services.NewTokenService(config, log, egressRepository),
+			Session: services.NewSessionService(config, log, egressRepository),
+			Mtls:    services.NewMtlsService(config, log, egressRepository, nil),
+			Dpop:    services.NewDpopService(config, log, egressRepository),
+		}
+		oauth = services.NewOAuthService(config, log, egressRepository, ingressRepository)
+		mw    = middleware.NewMiddleWare(config, log, ingressRepository.Token, ingressRepository.Session, nil, ingressRepository.Mtls, ingressRepository.Dpop, egressRepository)
+	)
+
+	now := time.Now()
+	sessions.sessions["actor-session"] = &models.Session{ID: "actor-session", UserID: actorID, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	actorToken, err := ingressRepository.Token.GenerateToken("support", []string{constants.PrmImpersonate}, &models.User{ID: actorID}, &models.TokenOptions{SessionID: "actor-session"})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	exchange := newRequestCtx()
+	exchange.Request.Header.SetMethod(fasthttp.MethodPost)
+	exchange.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	exchange.Request.SetBodyString("grant_type=" + constants.GrantTokenExchange +
+		"&actor_token=" + actorToken + "&actor_token_type=" + constants.TokenTypeAccessToken +
+		"&requested_subject=" + strconv.Itoa(targetID) + "&reason=support")
+	oauth.Token(exchange)
+
+	if exchange.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("token exchange: status %d, body %s", exchange.Response.StatusCode(), exchange.Response.Body())
+	}
+
+	// An unknown user is a bad request, not a crash
+	unknown := newRequestCtx()
+	unknown.Request.Header.SetMethod(fasthttp.MethodPost)
+	unknown.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	unknown.Request.SetBodyString("grant_type=" + constants.GrantTokenExchange +
+		"&actor_token=" + actorToken + "&actor_token_type=" + constants.TokenTypeAccessToken +
+		"&requested_subject=404&reason=support")
+	oauth.Token(unknown)
+
+	if unknown.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Fatalf("unknown requested_subject: status %d, body %s", unknown.Response.StatusCode(), unknown.Response.Body())
+	}
+
+	var issued models.TokenResponse
+	if err := json.Unmarshal(exchange.Response.Body(), &issued); err != nil {
+		t.Fatalf("token exchange response: %v", err)
+	}
+
+	authorize := func() (int, bool) {
+		var called bool
+		handler := mw.Authorization(constants.PrmInfoUser)(func(ctx *fasthttp.RequestCtx) {
+			called = true
+			if tokenInfo, _ := ctx.UserValue(constants.CtxTokenInfo).(*models.Token); tokenInfo == nil || tokenInfo.UserID != targetID || tokenInfo.Act == nil || tokenInfo.Act.UserID != actorID {
+				t.Errorf("unexpected token info %+v", tokenInfo)
+			}
+		})
+
+		ctx := newRequestCtx()
+		ctx.Request.Header.Set(constants.Authorization, "Bearer "+issued.AccessToken)
+		handler(ctx)
+		return ctx.Response.StatusCode(), called
+	}
+
+	if status, called := authorize(); !called {
+		t.Fatalf("impersonation token rejected with status %d", status)
+	}
+
+	// Signing the staff member out ends the impersonation too
+	delete(sessions.sessions, "actor-session")
+	if status, called := authorize(); called || status != fasthttp.StatusUnauthorized {
+		t.Fatalf("impersonation token accepted after the actor session ended, status %d", status)
+	}
+}
+
+// newRequestCtx returns a context served by the fake server of fasthttp, handlers derive timeouts from it
+func newRequestCtx() *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	return ctx
+}
+
+type cacheRepository struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *cacheRepository) Get(_ context.Context, key string, response any) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, found := c.values[key]
+	if !found {
+		return "", utils.ErrInvalidCacheKey
+	}
+	if response != nil {
+		if err := json.Unmarshal(value, response); err != nil {
+			return "", err
+		}
+	}
+	return string(value), nil
+}
+
+func (c *cacheRepository) GetDel(ctx context.Context, key string, response any) error {
+	if _, err := c.Get(ctx, key, response); err != nil {
+		return err
+	}
+	return c.Delete(ctx, key)
+}
+
+func (c *cacheRepository) Add(_ context.Context, key string, value any, _ time.Duration, _ constants.CacheStrategy) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = data
+	return nil
+}
+
+func (c *cacheRepository) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+func (c *cacheRepository) Incr(_ context.Context, key string, _ time.Duration) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, _ := strconv.Atoi(string(c.values[key]))
+	count++
+	c.values[key] = []byte(strconv.Itoa(count))
+	return count, nil
+}
+
+type sessionRepository struct {
+	egress.SessionRepositoryPorts
+	sessions map[string]*models.Session
+}
+
+func (r *sessionRepository) GetByID(_ context.Context, id string) (*models.Session, error) {
+	session, found := r.sessions[id]
+	if !found {
+		return nil, utils.ErrDocumentNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *sessionRepository) Touch(context.Context, string, time.Time) error {
+	return nil
+}
+
+type userRepository struct {
+	egress.UserRepositoryPorts
+	users map[int]*models.User
+}
+
+// GetByID follows the database repository, an unknown user is nil without an error
+func (r *userRepository) GetByID(_ context.Context, id int) (*models.User, error) {
+	return r.users[id], nil
+}
+
+type roleRepository struct {
+	egress.RoleRepositoryPorts
+}
+
+func (r *roleRepository) GetByID(context.Context, constants.Roles) (*models.Role, error) {
+	return nil, utils.ErrDocumentNotFound
+}
+
+type impersonationRepository struct {
+	egress.ImpersonationRepositoryPorts
+}
+
+func (r *impersonationRepository) Add(context.Context, *models.Impersonation) error {
+	return nil
+}
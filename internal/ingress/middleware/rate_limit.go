@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/constants"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/core/models"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/ingress/response"
+	"github.com/bhupendra-dudhwal/sso-gateway/internal/utils"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// RateLimit applies every configured token bucket matching the request.
+// The request is rejected with 429 as soon as one bucket is empty. Redis errors fail open.
+func (m *middleware) RateLimit(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if m.config.RateLimit == nil || !m.config.RateLimit.Enabled {
+			next(ctx)
+			return
+		}
+
+		var (
+			method = string(ctx.Method())
+			path   = string(ctx.Path())
+			result *models.RateLimitResult
+		)
+
+		for i := range m.config.RateLimit.Rules {
+			rule := &m.config.RateLimit.Rules[i]
+			if !rule.Matches(method, path) {
+				continue
+			}
+
+			value := m.rateLimitKey(ctx, rule.KeyType)
+			if value == "" {
+				continue
+			}
+
+			key := fmt.Sprintf("%s:%d:%s:%s", m.config.RateLimit.Prefix, i, rule.KeyType, value)
+
+			ctxVal, cancel := context.WithTimeout(ctx, time.Second)
+			res, err := m.egressRepository.RateLimit.Take(ctxVal, key, rule)
+			cancel()
+			if err != nil {
+				m.logger.Error("rate limit check failed", zap.String("requestID", utils.GetField(ctx, constants.CtxRequestID)), zap.Error(err))
+				continue
+			}
+
+			// Report the most restrictive bucket
+			if result == nil || !res.Allowed || (result.Allowed && res.Remaining < result.Remaining) {
+				result = res
+			}
+
+			if !res.Allowed {
+				break
+			}
+		}
+
+		if result == nil {
+			next(ctx)
+			return
+		}
+
+		ctx.Response.Header.Set(constants.RateLimitLimit.String(), strconv.Itoa(result.Limit))
+		ctx.Response.Header.Set(constants.RateLimitRemaining.String(), strconv.Itoa(result.Remaining))
+		ctx.Response.Header.Set(constants.RateLimitReset.String(), strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			reqID := utils.GetField(ctx, constants.CtxRequestID)
+			m.logger.Info("rate limit exceeded", zap.String("requestID", reqID), zap.String("path", path))
+
+			ctx.Response.Header.Set(constants.RetryAfter.String(), strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			response.NewResponse(reqID, m.config.App.Server.Compression, m.logger).
+				SetStatusCode(fasthttp.StatusTooManyRequests).
+				SetError(&models.Error{
+					Code:    "ME-RL-1",
+					Message: "Too many requests",
+				}).Send(ctx)
+			return
+		}
+
+		next(ctx)
+	}
+}
+
+// rateLimitKey resolves the bucket identity for the key type, empty when it cannot be resolved
+func (m *middleware) rateLimitKey(ctx *fasthttp.RequestCtx, keyType constants.RateLimitKey) string {
+	switch keyType {
+	case constants.RateLimitKeyIP:
+		return utils.ClientIP(ctx, m.config.App.Server.TrustProxy, m.config.App.Server.TrustedProxies)
+
+	case constants.RateLimitKeyUser:
+		authHeader := string(ctx.Request.Header.Peek(constants.Authorization))
+		if !strings.HasPrefix(authHeader, constants.AuthType) {
+			return ""
+		}
+
+		tokenInfo, err := m.tokenService.ResolveToken(ctx, strings.TrimPrefix(authHeader, constants.AuthType))
+		if err != nil || tokenInfo.UserID == 0 {
+			return ""
+		}
+		return strconv.Itoa(tokenInfo.UserID)
+
+	case constants.RateLimitKeyEmail:
+		var body struct {
+			Email string `json:"email"`
+		}
+		if err := json.Unmarshal(ctx.PostBody(), &body); err != nil {
+			return ""
+		}
+		return utils.SanitizeLower(body.Email)
+
+	case constants.RateLimitKeyClient:
+		return utils.Sanitize(string(ctx.Request.Header.Peek(constants.XClientID.String())))
+	}
+
+	return ""
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
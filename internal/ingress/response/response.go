@@ -48,6 +48,11 @@ func (r *response) SetToken(token string) ports.Response {
 	return r
 }
 
+func (r *response) SetRefreshToken(token string) ports.Response {
+	r.payload.RefreshToken = token
+	return r
+}
+
 func (r *response) SetPermission(permissions []string) ports.Response {
 	r.payload.Permissions = permissions
 	return r
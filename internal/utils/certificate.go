@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// LoadCertPool reads the PEM encoded CA certificates of the given files
+func LoadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", file, err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// CertThumbprint returns the unpadded base64url SHA-256 of the DER certificate, see RFC 8705
+func CertThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// RandomToken returns n random bytes encoded as unpadded base64url
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NumericCode returns a random decimal code of the given length, e.g. an OTP
+func NumericCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate numeric code: %w", err)
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
+
+// HashToken returns the hex SHA-256 of the given parts joined by ":".
+// Used to store secrets (OTPs, refresh tokens, API keys) without keeping them in clear.
+func HashToken(parts ...string) string {
+	h := sha256.New()
+	for i, part := range parts {
+		if i > 0 {
+			h.Write([]byte(":"))
+		}
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign returns the unpadded base64url HMAC-SHA256 of value
+func Sign(secret, value string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CsrfToken derives the CSRF token bound to an access token id, so it cannot be reused with another token
+func CsrfToken(secret, tokenID string) string {
+	return Sign(secret, tokenID)
+}
+
+// userCodeAlphabet has no vowels and no look-alike characters, see RFC 8628 section 6.1
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// UserCode returns a random code users can read and type, e.g. the user code of a device
+func UserCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate user code: %w", err)
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
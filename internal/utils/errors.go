@@ -10,4 +10,12 @@ var (
 	ErrDuplicate          error = errors.New("document already exists")
 	ErrDocumentNotFound   error = errors.New("document not found")
 	ErrInvalidCredentials error = errors.New("Please enter a valid credentials")
+	ErrInvalidChallenge   error = errors.New("invalid or expired challenge")
+	ErrInvalidSession     error = errors.New("session expired or revoked")
+	ErrSessionLimit       error = errors.New("maximum concurrent sessions reached")
+	ErrInvalidAccessToken error = errors.New("access token invalid, expired or revoked")
+	ErrInvalidAssertion   error = errors.New("invalid client assertion")
+	ErrInvalidCertificate error = errors.New("client certificate missing or not bound")
+	ErrInvalidDpopProof   error = errors.New("invalid DPoP proof")
+	ErrInvalidToken       error = errors.New("token invalid, expired or revoked")
 )
@@ -3,6 +3,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -27,6 +28,50 @@ func GetField(ctx *fasthttp.RequestCtx, key constants.Context) string {
 	return val
 }
 
+// GetPathParam returns the named router path parameter
+func GetPathParam(ctx *fasthttp.RequestCtx, name string) string {
+	val, ok := ctx.UserValue(name).(string)
+	if !ok {
+		return ""
+	}
+
+	return Sanitize(val)
+}
+
+// ClientIP returns the address of the caller. When trustProxy is set and the request comes from
+// one of the trusted proxies, X-Forwarded-For is read from the right and the first hop that is
+// not a trusted proxy is the caller, entries left of it are set by the client and ignored.
+// X-Real-IP is only read when the proxy sets no X-Forwarded-For.
+func ClientIP(ctx *fasthttp.RequestCtx, trustProxy bool, trustedProxies []string) string {
+	remoteIP := ctx.RemoteIP().String()
+	if !trustProxy || !IPInCIDRs(remoteIP, trustedProxies) {
+		return remoteIP
+	}
+
+	if xff := string(ctx.Request.Header.Peek(constants.XForwardedFor.String())); xff != "" {
+		hops := strings.Split(xff, ",")
+		caller := remoteIP
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+
+			caller = hop
+			if !IPInCIDRs(hop, trustedProxies) {
+				break
+			}
+		}
+		return caller
+	}
+
+	if ip := strings.TrimSpace(string(ctx.Request.Header.Peek(constants.XRealIP.String()))); net.ParseIP(ip) != nil {
+		return ip
+	}
+
+	return remoteIP
+}
+
 // findProjectRoot walks up from the current directory to find a directory containing go.mod.
 func FindProjectRoot() (string, error) {
 	dir, err := os.Getwd()
@@ -166,3 +211,33 @@ func PasswordStrengthValidation(min, max int) validation.Rule {
 		return nil
 	})
 }
+
+// Rule: value must be a CIDR block such as 10.0.0.0/8
+func CIDRValidation() validation.Rule {
+	return validation.By(func(value any) error {
+		cidr, ok := value.(string)
+		if !ok {
+			return errors.New("Invalid CIDR type")
+		}
+
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("Invalid CIDR %q", cidr)
+		}
+		return nil
+	})
+}
+
+// IPInCIDRs reports whether ip belongs to any of the CIDR blocks
+func IPInCIDRs(ip string, cidrs []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}